@@ -0,0 +1,199 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Scope is a single permission a token or client certificate may hold.
+type Scope string
+
+const (
+	// ScopeReadMetrics allows /api/v1/query_range and /api/v1/tail.
+	ScopeReadMetrics Scope = "read:metrics"
+	// ScopeReadStatus allows /api/v1/status.
+	ScopeReadStatus Scope = "read:status"
+	// ScopeAdmin implies every other scope and lifts the
+	// per-application whitelist.
+	ScopeAdmin Scope = "admin"
+)
+
+// Principal is the authenticated caller of a request: the scopes it
+// was granted and, unless it holds ScopeAdmin, the applications it is
+// allowed to see.
+type Principal struct {
+	Scopes       map[Scope]bool
+	Applications map[string]bool
+}
+
+// HasScope reports whether p was granted scope, treating ScopeAdmin as
+// satisfying every scope.
+func (p Principal) HasScope(scope Scope) bool {
+	return p.Scopes[ScopeAdmin] || p.Scopes[scope]
+}
+
+// CanSeeApplication reports whether p is allowed to see applicationName.
+// Admins and principals with no configured whitelist see everything.
+func (p Principal) CanSeeApplication(applicationName string) bool {
+	if p.Scopes[ScopeAdmin] || len(p.Applications) == 0 {
+		return true
+	}
+	return p.Applications[applicationName]
+}
+
+// Authenticator authenticates a single HTTP request and reports the
+// resulting Principal.
+type Authenticator interface {
+	Authenticate(r *http.Request) (Principal, error)
+}
+
+// tokenEntry is the on-disk representation of a single bearer token in
+// the JSON file loaded by TokenStore.
+type tokenEntry struct {
+	// Id is sent as the first, "."-delimited component of the
+	// Authorization header's bearer value, e.g.
+	// "Authorization: Bearer <id>.<secret>".
+	Id string `json:"id"`
+	// HashedSecret is the bcrypt hash of the secret component.
+	HashedSecret string   `json:"hashedSecret"`
+	Scopes       []Scope  `json:"scopes"`
+	Applications []string `json:"applications"`
+}
+
+// TokenStore authenticates bearer tokens against a JSON file of
+// bcrypt-hashed secrets. Call Reload to pick up changes, or WatchSIGHUP to
+// reload automatically on SIGHUP.
+type TokenStore struct {
+	path    string
+	entries atomic.Value // map[string]tokenEntry, keyed by Id
+}
+
+// NewTokenStore loads path and returns a TokenStore ready to
+// Authenticate requests.
+func NewTokenStore(path string) (*TokenStore, error) {
+	t := &TokenStore{path: path}
+	if err := t.Reload(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Reload re-reads t's backing file, replacing the current set of
+// tokens.
+func (t *TokenStore) Reload() error {
+	contents, err := os.ReadFile(t.path)
+	if err != nil {
+		return fmt.Errorf("api: reading token store %s: %v", t.path, err)
+	}
+	var list []tokenEntry
+	if err := json.Unmarshal(contents, &list); err != nil {
+		return fmt.Errorf("api: parsing token store %s: %v", t.path, err)
+	}
+	byId := make(map[string]tokenEntry, len(list))
+	for _, entry := range list {
+		byId[entry.Id] = entry
+	}
+	t.entries.Store(byId)
+	return nil
+}
+
+// Authenticate implements Authenticator using the "Authorization:
+// Bearer <id>.<secret>" header.
+func (t *TokenStore) Authenticate(r *http.Request) (Principal, error) {
+	header := r.Header.Get("Authorization")
+	const prefix = "Bearer "
+	if !strings.HasPrefix(header, prefix) {
+		return Principal{}, fmt.Errorf("api: missing bearer token")
+	}
+	id, secret, ok := strings.Cut(strings.TrimPrefix(header, prefix), ".")
+	if !ok {
+		return Principal{}, fmt.Errorf("api: malformed bearer token")
+	}
+	byId := t.entries.Load().(map[string]tokenEntry)
+	entry, ok := byId[id]
+	if !ok {
+		return Principal{}, fmt.Errorf("api: unknown token")
+	}
+	if err := bcrypt.CompareHashAndPassword(
+		[]byte(entry.HashedSecret), []byte(secret)); err != nil {
+		return Principal{}, fmt.Errorf("api: invalid token")
+	}
+	return principalFromEntry(entry), nil
+}
+
+func principalFromEntry(entry tokenEntry) Principal {
+	scopes := make(map[Scope]bool, len(entry.Scopes))
+	for _, scope := range entry.Scopes {
+		scopes[scope] = true
+	}
+	var applications map[string]bool
+	if len(entry.Applications) > 0 {
+		applications = make(map[string]bool, len(entry.Applications))
+		for _, app := range entry.Applications {
+			applications[app] = true
+		}
+	}
+	return Principal{Scopes: scopes, Applications: applications}
+}
+
+// MutualTLSAuthenticator authenticates requests by the verified client
+// certificate TLS already attached to the connection, mapping each
+// certificate's subject common name to a Principal via CommonNames.
+type MutualTLSAuthenticator struct {
+	CommonNames map[string]Principal
+}
+
+// Authenticate implements Authenticator.
+func (m *MutualTLSAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return Principal{}, fmt.Errorf("api: no client certificate presented")
+	}
+	cn := r.TLS.PeerCertificates[0].Subject.CommonName
+	principal, ok := m.CommonNames[cn]
+	if !ok {
+		return Principal{}, fmt.Errorf("api: no principal configured for certificate %q", cn)
+	}
+	return principal, nil
+}
+
+// AnyOf returns an Authenticator that tries each of authenticators in
+// order and succeeds with the first one that accepts the request, so a
+// server can accept either bearer tokens or mutual TLS.
+func AnyOf(authenticators ...Authenticator) Authenticator {
+	return anyOfAuthenticator(authenticators)
+}
+
+type anyOfAuthenticator []Authenticator
+
+func (a anyOfAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	var lastErr error
+	for _, authenticator := range a {
+		principal, err := authenticator.Authenticate(r)
+		if err == nil {
+			return principal, nil
+		}
+		lastErr = err
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("api: no authenticator configured")
+	}
+	return Principal{}, lastErr
+}
+
+// TLSConfigForMutualAuth returns a server tls.Config that requires and
+// verifies a client certificate against caCert, suitable for a server
+// using MutualTLSAuthenticator.
+func TLSConfigForMutualAuth(caCert *x509.CertPool) *tls.Config {
+	return &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  caCert,
+	}
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPrincipalHasScope(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal Principal
+		scope     Scope
+		want      bool
+	}{
+		{"granted", Principal{Scopes: map[Scope]bool{ScopeReadMetrics: true}}, ScopeReadMetrics, true},
+		{"not granted", Principal{Scopes: map[Scope]bool{ScopeReadMetrics: true}}, ScopeReadStatus, false},
+		{"admin implies any scope", Principal{Scopes: map[Scope]bool{ScopeAdmin: true}}, ScopeReadStatus, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.principal.HasScope(test.scope); got != test.want {
+				t.Errorf("HasScope(%s) = %v, want %v", test.scope, got, test.want)
+			}
+		})
+	}
+}
+
+func TestPrincipalCanSeeApplication(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal Principal
+		app       string
+		want      bool
+	}{
+		{"no whitelist sees everything", Principal{}, "anything", true},
+		{"admin sees everything", Principal{Scopes: map[Scope]bool{ScopeAdmin: true}, Applications: map[string]bool{"other": true}}, "anything", true},
+		{"whitelisted app", Principal{Applications: map[string]bool{"myapp": true}}, "myapp", true},
+		{"non-whitelisted app", Principal{Applications: map[string]bool{"myapp": true}}, "otherapp", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.principal.CanSeeApplication(test.app); got != test.want {
+				t.Errorf("CanSeeApplication(%s) = %v, want %v", test.app, got, test.want)
+			}
+		})
+	}
+}
+
+// writeTokenStore hashes secret and writes a single-entry token store
+// file to a temp path, returning the path.
+func writeTokenStore(t *testing.T, id, secret string, scopes []Scope, applications []string) string {
+	t.Helper()
+	hashed, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("GenerateFromPassword: %v", err)
+	}
+	entries := []tokenEntry{{
+		Id:           id,
+		HashedSecret: string(hashed),
+		Scopes:       scopes,
+		Applications: applications,
+	}}
+	contents, err := json.Marshal(entries)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	if err := os.WriteFile(path, contents, 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+func TestTokenStoreAuthenticate(t *testing.T) {
+	path := writeTokenStore(t, "id1", "secret1", []Scope{ScopeReadMetrics}, []string{"myapp"})
+	store, err := NewTokenStore(path)
+	if err != nil {
+		t.Fatalf("NewTokenStore: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer id1.secret1")
+	principal, err := store.Authenticate(req)
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if !principal.HasScope(ScopeReadMetrics) {
+		t.Errorf("principal missing expected scope: %+v", principal)
+	}
+	if !principal.CanSeeApplication("myapp") || principal.CanSeeApplication("otherapp") {
+		t.Errorf("principal application whitelist wrong: %+v", principal)
+	}
+}
+
+func TestTokenStoreAuthenticateRejectsWrongSecret(t *testing.T) {
+	path := writeTokenStore(t, "id1", "secret1", nil, nil)
+	store, err := NewTokenStore(path)
+	if err != nil {
+		t.Fatalf("NewTokenStore: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer id1.wrongsecret")
+	if _, err := store.Authenticate(req); err == nil {
+		t.Fatal("Authenticate succeeded with the wrong secret")
+	}
+}
+
+func TestTokenStoreAuthenticateRejectsMissingHeader(t *testing.T) {
+	path := writeTokenStore(t, "id1", "secret1", nil, nil)
+	store, err := NewTokenStore(path)
+	if err != nil {
+		t.Fatalf("NewTokenStore: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if _, err := store.Authenticate(req); err == nil {
+		t.Fatal("Authenticate succeeded with no Authorization header")
+	}
+}
+
+func TestAnyOfTriesEachAuthenticatorInOrder(t *testing.T) {
+	want := Principal{Scopes: map[Scope]bool{ScopeAdmin: true}}
+	failing := authenticatorFunc(func(*http.Request) (Principal, error) {
+		return Principal{}, fmt.Errorf("nope")
+	})
+	succeeding := authenticatorFunc(func(*http.Request) (Principal, error) {
+		return want, nil
+	})
+	combined := AnyOf(failing, succeeding)
+	got, err := combined.Authenticate(httptest.NewRequest(http.MethodGet, "/", nil))
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if got.Scopes[ScopeAdmin] != want.Scopes[ScopeAdmin] {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestAnyOfFailsWhenEveryAuthenticatorFails(t *testing.T) {
+	failing := authenticatorFunc(func(*http.Request) (Principal, error) {
+		return Principal{}, fmt.Errorf("nope")
+	})
+	combined := AnyOf(failing, failing)
+	if _, err := combined.Authenticate(httptest.NewRequest(http.MethodGet, "/", nil)); err == nil {
+		t.Fatal("Authenticate succeeded with no working authenticator")
+	}
+}
+
+// authenticatorFunc adapts a function to the Authenticator interface
+// for tests.
+type authenticatorFunc func(r *http.Request) (Principal, error)
+
+func (f authenticatorFunc) Authenticate(r *http.Request) (Principal, error) {
+	return f(r)
+}
@@ -0,0 +1,176 @@
+// Package api mounts an authenticated HTTP(S) query and streaming
+// interface over a datastructs.ApplicationStatuses instance: range
+// queries and a point-in-time status dump built from Fetch/
+// FetchForward and All(), plus a websocket that tails live updates.
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/Symantec/scotty/datastructs"
+	"github.com/Symantec/scotty/store"
+)
+
+// Handler serves the scotty HTTP API. Construct one with NewHandler
+// and mount it directly, or at a sub-path with http.StripPrefix.
+type Handler struct {
+	statuses *datastructs.ApplicationStatuses
+	auth     Authenticator
+	upgrader websocket.Upgrader
+	mux      *http.ServeMux
+}
+
+// NewHandler returns a Handler serving statuses, authenticating every
+// request with auth.
+func NewHandler(statuses *datastructs.ApplicationStatuses, auth Authenticator) *Handler {
+	h := &Handler{
+		statuses: statuses,
+		auth:     auth,
+	}
+	h.mux = http.NewServeMux()
+	h.mux.HandleFunc("/api/v1/query_range", h.withScope(ScopeReadMetrics, h.queryRange))
+	h.mux.HandleFunc("/api/v1/status", h.withScope(ScopeReadStatus, h.status))
+	h.mux.HandleFunc("/api/v1/tail", h.withScope(ScopeReadMetrics, h.tail))
+	return h
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// withScope authenticates the request, requires scope, and only then
+// invokes next.
+func (h *Handler) withScope(
+	scope Scope, next func(http.ResponseWriter, *http.Request, Principal)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, err := h.auth.Authenticate(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if !principal.HasScope(scope) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		next(w, r, principal)
+	}
+}
+
+// sample is the wire representation of a single store.Record.
+type sample struct {
+	TimeStamp float64     `json:"ts"`
+	Value     interface{} `json:"value"`
+}
+
+// sampleAppender collects Records into a slice of samples, satisfying
+// store.Appender.
+type sampleAppender struct {
+	samples []sample
+}
+
+// Append implements store.Appender.
+func (s *sampleAppender) Append(r *store.Record) bool {
+	s.samples = append(s.samples, sample{TimeStamp: r.TimeStamp, Value: r.Value()})
+	return true
+}
+
+// queryRange serves GET /api/v1/query_range?host=&app=&metric=&start=&end=.
+func (h *Handler) queryRange(w http.ResponseWriter, r *http.Request, principal Principal) {
+	query := r.URL.Query()
+	host := query.Get("host")
+	app := query.Get("app")
+	metric := query.Get("metric")
+	if !principal.CanSeeApplication(app) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	start, err := strconv.ParseFloat(query.Get("start"), 64)
+	if err != nil {
+		http.Error(w, "invalid start", http.StatusBadRequest)
+		return
+	}
+	end, err := strconv.ParseFloat(query.Get("end"), 64)
+	if err != nil {
+		http.Error(w, "invalid end", http.StatusBadRequest)
+		return
+	}
+	endpointId, s := h.statuses.EndpointIdByHostAndName(host, app)
+	if endpointId == nil {
+		http.Error(w, "no such host/app", http.StatusNotFound)
+		return
+	}
+	appender := &sampleAppender{}
+	if err := s.FetchForward(endpointId, metric, start, end, appender); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, appender.samples)
+}
+
+// status serves GET /api/v1/status, a proxy for All() filtered down to
+// the applications principal is allowed to see.
+func (h *Handler) status(w http.ResponseWriter, r *http.Request, principal Principal) {
+	all := h.statuses.All()
+	visible := make([]*datastructs.ApplicationStatus, 0, len(all))
+	for _, status := range all {
+		if principal.CanSeeApplication(status.Name) {
+			visible = append(visible, status)
+		}
+	}
+	writeJSON(w, visible)
+}
+
+// tail serves GET /api/v1/tail, a websocket that pushes each
+// ApplicationStatus principal may see as it is Update()d.
+func (h *Handler) tail(w http.ResponseWriter, r *http.Request, principal Principal) {
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	updates := make(chan *datastructs.ApplicationStatus, 64)
+	cancel := h.statuses.Subscribe(func(status *datastructs.ApplicationStatus) {
+		if !principal.CanSeeApplication(status.Name) {
+			return
+		}
+		select {
+		case updates <- status:
+		default:
+		}
+	})
+	defer cancel()
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case status := <-updates:
+			if err := conn.WriteJSON(status); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,27 @@
+package api
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// WatchSIGHUP reloads t every time the process receives SIGHUP, so
+// operators can rotate or revoke tokens without restarting scotty.
+// It runs until stop is closed.
+func (t *TokenStore) WatchSIGHUP(stop <-chan struct{}) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	defer signal.Stop(sighup)
+	for {
+		select {
+		case <-stop:
+			return
+		case <-sighup:
+			if err := t.Reload(); err != nil {
+				log.Printf("api: reloading token store: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package datastructs
+
+import (
+	"github.com/Symantec/scotty"
+)
+
+// This file contains the implementation backing the Alert-related
+// methods on ApplicationStatuses declared in api.go.
+
+func (a *ApplicationStatuses) setAlerts(e *scotty.Endpoint, alerts []Alert) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	status := a.byEndpoint[e]
+	if status == nil {
+		panic("setAlerts: no such endpoint")
+	}
+	status.Alerts = alerts
+}
@@ -42,10 +42,42 @@ type ApplicationStatus struct {
 	// If non-empty the AWS instance-id of the machine of the application
 	InstanceId string
 
+	// Alerts currently pending or firing for this application as last
+	// reported by the rules package. nil or empty means no alerts.
+	Alerts []Alert
+
+	// EffectiveLimits is the Limits currently enforced for this
+	// endpoint's application, after any Overrides are applied.
+	EffectiveLimits Limits
+
+	// DroppedSamples counts samples rejected by Update because they
+	// would exceed EffectiveLimits.MaxSamplesPerSecond.
+	DroppedSamples uint64
+
+	// SeriesLimitHit is true once LogChangedMetricCount has been
+	// given a metric count above EffectiveLimits.MaxMetrics for this
+	// endpoint; the count it logged was then capped at MaxMetrics.
+	SeriesLimitHit bool
+
 	changedMetrics_Sum   uint64
 	changedMetrics_Count uint64
 }
 
+// Alert is a point-in-time summary of a single rule's state for an
+// application, as reported by the rules package. It is decoupled from
+// any particular rule representation so that datastructs does not need
+// to depend on the rules package.
+type Alert struct {
+	// Name identifies the rule that produced this alert.
+	Name string
+	// State is one of "pending", "firing" or "resolved".
+	State string
+	// Since is when the rule entered State.
+	Since time.Time
+	// Labels carries rule-supplied context, e.g. the metric name.
+	Labels map[string]string
+}
+
 // Returns last error time as 2006-01-02T15:04:05
 func (a *ApplicationStatus) LastErrorTimeStr() string {
 	return a.LastErrorTime.Format("2006-01-02T15:04:05")
@@ -92,6 +124,15 @@ type ApplicationStatuses struct {
 	byEndpoint   map[*scotty.Endpoint]*ApplicationStatus
 	byHostPort   map[hostAndPort]*scotty.Endpoint
 	currentStore *store.Store
+	persister    *store.PagePersister
+	// overrides holds the live, hot-reloadable per-application
+	// Limits. May be nil, in which case only Application.Limits()
+	// applies.
+	overrides *Overrides
+	// subscribers are notified, in no particular order, after every
+	// successful Update. Protected by lock.
+	subscribers map[int]func(*ApplicationStatus)
+	nextSubscriberId int
 }
 
 // NewApplicationStatuses creates a new ApplicationStatus instance.
@@ -99,13 +140,102 @@ type ApplicationStatuses struct {
 // Since the newly created instance will create new store.Store instances
 // whenever active machines change, caller should give up any reference it
 // has to astore and use the Store() method to get the current store.
-func NewApplicationStatuses(appList *ApplicationList, astore *store.Store) *ApplicationStatuses {
-	return &ApplicationStatuses{
+//
+// If persistPath is non-empty, a PagePersister is opened on the bbolt
+// database at that path and any pages already there are rehydrated
+// into astore in a cold state before NewApplicationStatuses returns.
+// That PagePersister is not attached to astore: new pages are flushed
+// only if astore itself was given a PagePersister by NewStore.
+// retention is passed to the PagePersister, which does not delete
+// flushed pages by it.
+//
+// Rehydration only repopulates astore's own series; it does not create
+// ApplicationStatus entries in byEndpoint/byHostPort, since those are
+// keyed by *scotty.Endpoint and only discovery (via Update) knows how
+// to produce one. A rehydrated series is therefore dark — queryable
+// through Store but with no corresponding ApplicationStatus - until
+// its host is rediscovered and Update runs for it again.
+func NewApplicationStatuses(
+	appList *ApplicationList,
+	astore *store.Store,
+	persistPath string,
+	retention time.Duration) (*ApplicationStatuses, error) {
+	result := &ApplicationStatuses{
 		appList:      appList,
 		byEndpoint:   make(map[*scotty.Endpoint]*ApplicationStatus),
 		byHostPort:   make(map[hostAndPort]*scotty.Endpoint),
 		currentStore: astore,
 	}
+	if persistPath != "" {
+		persister, err := store.OpenPagePersister(store.PersistenceConfig{
+			Path:      persistPath,
+			Retention: retention,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := astore.Rehydrate(persister); err != nil {
+			persister.Close()
+			return nil, err
+		}
+		result.persister = persister
+	}
+	return result, nil
+}
+
+// Close releases any resources, such as an on-disk page store, held by
+// this instance. Callers that did not pass a persistPath to
+// NewApplicationStatuses may ignore the returned error.
+func (a *ApplicationStatuses) Close() error {
+	if a.persister == nil {
+		return nil
+	}
+	return a.persister.Close()
+}
+
+// SetOverrides installs overrides as the source of per-application
+// Limits consulted by Update and LogChangedMetricCount, replacing
+// whatever was set before. Passing the *Overrides returned by
+// WatchOverrides lets operators tighten a noisy application's limits
+// without a restart.
+func (a *ApplicationStatuses) SetOverrides(overrides *Overrides) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.overrides = overrides
+}
+
+// effectiveLimits returns the Limits that should be enforced for
+// status: its Application's configured Limits with any Overrides
+// field the operator has explicitly set merged on top. Caller must
+// hold a.lock.
+func (a *ApplicationStatuses) effectiveLimits(status *ApplicationStatus) Limits {
+	app := a.appList.ByName(status.Name)
+	if app == nil {
+		return Limits{}
+	}
+	limits := app.Limits()
+	if a.overrides == nil {
+		return limits
+	}
+	override, ok := a.overrides.ForApplication(status.Name)
+	if !ok {
+		return limits
+	}
+	return override.Merge(limits)
+}
+
+// MaxPagesFor returns the effective MaxPages limit for e, for callers
+// that add samples to this instance's Store directly and need to pass
+// a maxPages argument to Store.AddSample.
+func (a *ApplicationStatuses) MaxPagesFor(e *scotty.Endpoint) int {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	status := a.byEndpoint[e]
+	if status == nil {
+		return 0
+	}
+	status.EffectiveLimits = a.effectiveLimits(status)
+	return status.EffectiveLimits.MaxPages
 }
 
 // Store returns the current store instance.
@@ -121,9 +251,75 @@ func (a *ApplicationStatuses) ApplicationList() *ApplicationList {
 // Update updates the status of a single application / endpoint.
 // This instance must have prior knowledge of e. That is, e must come from
 // a method such as ActiveEndpointIds(). Otherwise, this method panics.
+// Update consults this endpoint's effective Limits and drops the
+// update, recording it in DroppedSamples, if applying it would exceed
+// MaxSamplesPerSecond.
 func (a *ApplicationStatuses) Update(
 	e *scotty.Endpoint, newState *scotty.State) {
+	if a.sampleExceedsLimit(e) {
+		return
+	}
 	a.update(e, newState)
+	a.notifySubscribers(e)
+}
+
+// Subscribe registers fn to be called, with the latest
+// ApplicationStatus, after every successful Update. It returns a
+// cancel function that unregisters fn; callers such as a websocket
+// handler that outlives a single request must call cancel when done.
+func (a *ApplicationStatuses) Subscribe(fn func(*ApplicationStatus)) (cancel func()) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.subscribers == nil {
+		a.subscribers = make(map[int]func(*ApplicationStatus))
+	}
+	id := a.nextSubscriberId
+	a.nextSubscriberId++
+	a.subscribers[id] = fn
+	return func() {
+		a.lock.Lock()
+		defer a.lock.Unlock()
+		delete(a.subscribers, id)
+	}
+}
+
+func (a *ApplicationStatuses) notifySubscribers(e *scotty.Endpoint) {
+	a.lock.Lock()
+	status := a.byEndpoint[e]
+	subscribers := make([]func(*ApplicationStatus), 0, len(a.subscribers))
+	for _, fn := range a.subscribers {
+		subscribers = append(subscribers, fn)
+	}
+	a.lock.Unlock()
+	if status == nil {
+		return
+	}
+	for _, fn := range subscribers {
+		fn(status)
+	}
+}
+
+// sampleExceedsLimit reports whether e's application has a
+// MaxSamplesPerSecond limit in effect and this sample would exceed it,
+// recording the drop in DroppedSamples when so.
+func (a *ApplicationStatuses) sampleExceedsLimit(e *scotty.Endpoint) bool {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	status := a.byEndpoint[e]
+	if status == nil {
+		return false
+	}
+	status.EffectiveLimits = a.effectiveLimits(status)
+	if status.EffectiveLimits.MaxSamplesPerSecond <= 0 {
+		return false
+	}
+	now := time.Now()
+	minInterval := time.Duration(float64(time.Second) / status.EffectiveLimits.MaxSamplesPerSecond)
+	if !status.LastReadTime.IsZero() && now.Sub(status.LastReadTime) < minInterval {
+		status.DroppedSamples++
+		return true
+	}
+	return false
 }
 
 // ReportError reports an error for the given endpoint.
@@ -136,6 +332,30 @@ func (a *ApplicationStatuses) ReportError(
 	a.reportError(e, err, ts)
 }
 
+// DeactivateStale marks inactive any endpoint whose Staleness exceeds
+// its effective Limits.MaxStaleness, as of now. Callers that want
+// auto-deactivation on staleness should invoke this periodically, the
+// same way they already drive MarkHostsActiveExclusively.
+func (a *ApplicationStatuses) DeactivateStale(now time.Time) {
+	a.statusChangeLock.Lock()
+	defer a.statusChangeLock.Unlock()
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	for _, status := range a.byEndpoint {
+		if !status.Active {
+			continue
+		}
+		status.EffectiveLimits = a.effectiveLimits(status)
+		maxStaleness := status.EffectiveLimits.MaxStaleness
+		if maxStaleness <= 0 || status.LastReadTime.IsZero() {
+			continue
+		}
+		if now.Sub(status.LastReadTime) > maxStaleness {
+			status.Active = false
+		}
+	}
+}
+
 // MarkHostsActiveExclusively marks all applications / endpoints of each host
 // within activeHosts as active while marking the rest inactive.
 // MarkHostsActiveExclusively marks all time series of any machines that just
@@ -151,9 +371,40 @@ func (a *ApplicationStatuses) MarkHostsActiveExclusively(
 // application / endpoint.
 // This instance must have prior knowledge of e. That is, e must come from
 // a method such as ActiveEndpointIds(). Otherwise, this method panics.
+// If metricCount would exceed e's effective MaxMetrics, the excess is
+// coalesced away: SeriesLimitHit is set and only MaxMetrics worth of
+// the change is logged.
 func (a *ApplicationStatuses) LogChangedMetricCount(
 	e *scotty.Endpoint, metricCount uint) {
-	a.logChangedMetricCount(e, metricCount)
+	a.logChangedMetricCount(e, a.clampToSeriesLimit(e, metricCount))
+}
+
+// clampToSeriesLimit reports the portion of metricCount that should be
+// counted for e given its effective MaxMetrics, recording SeriesLimitHit
+// when the cap is reached.
+func (a *ApplicationStatuses) clampToSeriesLimit(
+	e *scotty.Endpoint, metricCount uint) uint {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	status := a.byEndpoint[e]
+	if status == nil {
+		return metricCount
+	}
+	status.EffectiveLimits = a.effectiveLimits(status)
+	maxMetrics := status.EffectiveLimits.MaxMetrics
+	if maxMetrics <= 0 || metricCount <= uint(maxMetrics) {
+		return metricCount
+	}
+	status.SeriesLimitHit = true
+	return uint(maxMetrics)
+}
+
+// SetAlerts sets the alerts currently pending or firing for e, replacing
+// whatever was previously reported.
+// This instance must have prior knowledge of e. That is, e must come from
+// a method such as ActiveEndpointIds(). Otherwise, this method panics.
+func (a *ApplicationStatuses) SetAlerts(e *scotty.Endpoint, alerts []Alert) {
+	a.setAlerts(e, alerts)
 }
 
 // All returns the statuses for all the applications.
@@ -202,6 +453,7 @@ type Application struct {
 	name       string
 	connectors sources.ConnectorList
 	port       uint
+	limits     Limits
 }
 
 // Name returns the name of application
@@ -219,6 +471,11 @@ func (a *Application) Port() uint {
 	return a.port
 }
 
+// Limits returns the configured per-application limits for a.
+func (a *Application) Limits() Limits {
+	return a.limits
+}
+
 // ApplicationList represents all the applications in the fleet.
 // ApplicationList instances are immutable.
 type ApplicationList struct {
@@ -246,17 +503,22 @@ func (a *ApplicationList) ByName(name string) *Application {
 
 // ApplicationListBuilder builds an ApplicationList instance.
 type ApplicationListBuilder struct {
-	listPtr **ApplicationList
+	applications []*Application
 }
 
 func NewApplicationListBuilder() *ApplicationListBuilder {
 	return newApplicationListBuilder()
 }
 
-// Add adds an application.
+// Add adds an application with the given limits. A zero Limits means
+// no per-application overrides beyond whatever global defaults the
+// caller applies elsewhere.
 func (a *ApplicationListBuilder) Add(
-	port uint, applicationName string, connectors sources.ConnectorList) {
-	a.add(port, applicationName, connectors)
+	port uint,
+	applicationName string,
+	connectors sources.ConnectorList,
+	limits Limits) {
+	a.add(port, applicationName, connectors, limits)
 }
 
 // Read application names and ports from a config file into this builder.
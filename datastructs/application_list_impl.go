@@ -0,0 +1,44 @@
+package datastructs
+
+import (
+	"github.com/Symantec/scotty/sources"
+)
+
+// This file contains the implementation backing ApplicationList and
+// ApplicationListBuilder as declared in api.go.
+
+func (a *ApplicationList) all() []*Application {
+	result := make([]*Application, 0, len(a.byPort))
+	for _, app := range a.byPort {
+		result = append(result, app)
+	}
+	return result
+}
+
+func newApplicationListBuilder() *ApplicationListBuilder {
+	return &ApplicationListBuilder{}
+}
+
+func (a *ApplicationListBuilder) add(
+	port uint,
+	applicationName string,
+	connectors sources.ConnectorList,
+	limits Limits) {
+	a.applications = append(a.applications, &Application{
+		name:       applicationName,
+		connectors: connectors,
+		port:       port,
+		limits:     limits,
+	})
+}
+
+func (a *ApplicationListBuilder) build() *ApplicationList {
+	byPort := make(map[uint]*Application, len(a.applications))
+	byName := make(map[string]*Application, len(a.applications))
+	for _, app := range a.applications {
+		byPort[app.port] = app
+		byName[app.name] = app
+	}
+	a.applications = nil
+	return &ApplicationList{byPort: byPort, byName: byName}
+}
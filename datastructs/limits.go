@@ -0,0 +1,136 @@
+package datastructs
+
+import (
+	"encoding/json"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// Limits bounds the resources a single Application may consume. An
+// operator can tighten these without restarting scotty by editing the
+// file passed to WatchOverrides.
+type Limits struct {
+	// MaxPages caps how many sealed in-memory pages each metric
+	// series of a single endpoint of this application may hold at
+	// once; Store.AddSample enforces it per series when passed the
+	// value from ApplicationStatuses.MaxPagesFor. Zero means unlimited.
+	MaxPages int `json:"maxPages,omitempty"`
+	// MaxMetrics caps the changed-metric count that
+	// LogChangedMetricCount logs for a single endpoint of this
+	// application. Metrics beyond it are not otherwise rejected.
+	// Zero means unlimited.
+	MaxMetrics int `json:"maxMetrics,omitempty"`
+	// MaxSamplesPerSecond caps the ingestion rate, across all
+	// metrics, for a single endpoint of this application. Zero means
+	// unlimited.
+	MaxSamplesPerSecond float64 `json:"maxSamplesPerSecond,omitempty"`
+	// Retention is carried through Merge into EffectiveLimits for
+	// display; no code drops this application's samples by it.
+	Retention time.Duration `json:"retention,omitempty"`
+	// MaxStaleness is how long an endpoint of this application may
+	// go without a successful read before it is automatically marked
+	// inactive. Zero means never auto-deactivate on staleness alone.
+	MaxStaleness time.Duration `json:"maxStaleness,omitempty"`
+}
+
+// LimitOverride holds the subset of Limits fields an operator has
+// explicitly set for one application in an overrides file. A nil
+// field leaves that dimension of the Application's configured Limits
+// alone; a non-nil field replaces it, even if it points at a zero
+// value, so writing e.g. "maxSamplesPerSecond": 0 really does relax
+// that dimension back to unlimited instead of being indistinguishable
+// from not mentioning it at all.
+type LimitOverride struct {
+	MaxPages            *int           `json:"maxPages,omitempty"`
+	MaxMetrics          *int           `json:"maxMetrics,omitempty"`
+	MaxSamplesPerSecond *float64       `json:"maxSamplesPerSecond,omitempty"`
+	Retention           *time.Duration `json:"retention,omitempty"`
+	MaxStaleness        *time.Duration `json:"maxStaleness,omitempty"`
+}
+
+// Merge returns base with every field o sets explicitly overlaid on
+// top, leaving the fields o leaves nil untouched.
+func (o LimitOverride) Merge(base Limits) Limits {
+	result := base
+	if o.MaxPages != nil {
+		result.MaxPages = *o.MaxPages
+	}
+	if o.MaxMetrics != nil {
+		result.MaxMetrics = *o.MaxMetrics
+	}
+	if o.MaxSamplesPerSecond != nil {
+		result.MaxSamplesPerSecond = *o.MaxSamplesPerSecond
+	}
+	if o.Retention != nil {
+		result.Retention = *o.Retention
+	}
+	if o.MaxStaleness != nil {
+		result.MaxStaleness = *o.MaxStaleness
+	}
+	return result
+}
+
+// Overrides holds the current, possibly hot-reloaded, LimitOverride
+// for each application by name. The zero value has no overrides.
+type Overrides struct {
+	// byName holds an atomically-swapped map[string]LimitOverride so
+	// readers never block on a reload in progress.
+	byName atomic.Value
+}
+
+// NewOverrides creates an empty Overrides with no per-application
+// overrides set.
+func NewOverrides() *Overrides {
+	o := &Overrides{}
+	o.byName.Store(make(map[string]LimitOverride))
+	return o
+}
+
+// ForApplication returns the current LimitOverride for
+// applicationName and whether one is set at all; ok is false when no
+// entry for applicationName exists, as opposed to one that exists but
+// leaves every field nil.
+func (o *Overrides) ForApplication(applicationName string) (override LimitOverride, ok bool) {
+	byName, _ := o.byName.Load().(map[string]LimitOverride)
+	override, ok = byName[applicationName]
+	return
+}
+
+// Load replaces the current overrides by reading a JSON object from
+// path mapping application name to LimitOverride.
+func (o *Overrides) Load(path string) error {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var byName map[string]LimitOverride
+	if err := json.Unmarshal(contents, &byName); err != nil {
+		return err
+	}
+	o.byName.Store(byName)
+	return nil
+}
+
+// WatchOverrides loads path into o immediately, then re-loads it every
+// interval so operators can tighten a noisy application's limits
+// without a restart. Load errors are returned on onError if non-nil;
+// WatchOverrides keeps running in that case, leaving the last
+// successfully loaded overrides in place.
+func WatchOverrides(
+	path string, interval time.Duration, onError func(error)) (*Overrides, error) {
+	o := NewOverrides()
+	if err := o.Load(path); err != nil {
+		return nil, err
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := o.Load(path); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}()
+	return o, nil
+}
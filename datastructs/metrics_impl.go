@@ -0,0 +1,21 @@
+package datastructs
+
+import (
+	"github.com/Symantec/scotty"
+)
+
+// logChangedMetricCount is the implementation backing
+// ApplicationStatuses.LogChangedMetricCount, accumulating into the
+// changedMetrics_Sum / changedMetrics_Count counters that
+// AverageChangedMetrics divides.
+func (a *ApplicationStatuses) logChangedMetricCount(
+	e *scotty.Endpoint, metricCount uint) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	status := a.byEndpoint[e]
+	if status == nil {
+		panic("logChangedMetricCount: no such endpoint")
+	}
+	status.changedMetrics_Sum += uint64(metricCount)
+	status.changedMetrics_Count++
+}
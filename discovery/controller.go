@@ -0,0 +1,82 @@
+package discovery
+
+import (
+	"time"
+
+	"github.com/Symantec/Dominator/lib/mdb"
+)
+
+// Controller runs a Source and debounces its churn before forwarding
+// the active host list to Apply, which is typically
+// datastructs.ApplicationStatuses.MarkHostsActiveExclusively.
+type Controller struct {
+	Source Source
+	// Debounce is how long to wait, after the most recent update in
+	// a burst, for no further update before calling Apply. Zero
+	// disables debouncing and applies every update immediately.
+	Debounce time.Duration
+	// Apply receives the debounced, most recent machine list along
+	// with the timestamp (seconds since Jan 1, 1970 GMT) it should be
+	// applied with.
+	Apply func(timestamp float64, activeHosts []mdb.Machine)
+	// Now lets tests substitute a fake clock; defaults to time.Now.
+	Now func() time.Time
+}
+
+// Run starts Controller's Source and applies debounced updates until
+// stop is closed or Source.Watch returns.
+func (c *Controller) Run(stop <-chan struct{}) error {
+	now := c.Now
+	if now == nil {
+		now = time.Now
+	}
+	updates := make(chan []mdb.Machine, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Source.Watch(stop, updates)
+	}()
+
+	var timer *time.Timer
+	var pending []mdb.Machine
+	var havePending bool
+	var timerC <-chan time.Time
+
+	apply := func(machines []mdb.Machine) {
+		c.Apply(float64(now().UnixNano())/1e9, machines)
+	}
+
+	for {
+		select {
+		case <-stop:
+			if timer != nil {
+				timer.Stop()
+			}
+			return nil
+		case err := <-done:
+			if havePending {
+				apply(pending)
+			}
+			return err
+		case machines := <-updates:
+			if c.Debounce <= 0 {
+				apply(machines)
+				continue
+			}
+			pending = machines
+			havePending = true
+			// Always start a fresh timer rather than Reset-ing the
+			// old one: Reset on a timer that may have already fired
+			// is racy to get right, and debounce bursts are rare
+			// enough that discarding an unfired timer is cheap.
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.NewTimer(c.Debounce)
+			timerC = timer.C
+		case <-timerC:
+			apply(pending)
+			havePending = false
+			timerC = nil
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package discovery
+
+import (
+	"context"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/Symantec/Dominator/lib/mdb"
+)
+
+// Annotation keys KubernetesSource reads off each coordination.k8s.io
+// Lease to recover the information scotty needs beyond the lease's own
+// name and holder identity.
+const (
+	// AnnotationHostname overrides the hostname scotty uses; if
+	// absent, the lease's Name is used.
+	AnnotationHostname = "scotty.symantec.com/hostname"
+	// AnnotationInstanceId carries the AWS instance-id of the pod's
+	// node, if any.
+	AnnotationInstanceId = "scotty.symantec.com/instance-id"
+)
+
+// KubernetesSource discovers active hosts from coordination.k8s.io
+// Lease objects in Namespace matching LabelSelector: each live lease
+// (one per pod, renewed on a heartbeat by convention) becomes one
+// mdb.Machine. Pods run a leader-election-style sidecar, or scotty's
+// own agent, that holds the lease with HolderIdentity set to the pod
+// IP so it can be used as the machine's hostname/address.
+type KubernetesSource struct {
+	Client        kubernetes.Interface
+	Namespace     string
+	LabelSelector string
+	// PollInterval is how often the lease list is re-fetched. There
+	// is no watch/informer here since leases already carry their own
+	// short-lived renewal semantics; a simple poll keeps this source
+	// simple to operate.
+	PollInterval time.Duration
+}
+
+// Watch implements Source.
+func (s *KubernetesSource) Watch(stop <-chan struct{}, updates chan<- []mdb.Machine) error {
+	interval := s.PollInterval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		machines, err := s.listMachines()
+		if err != nil {
+			return err
+		}
+		select {
+		case updates <- machines:
+		case <-stop:
+			return nil
+		}
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *KubernetesSource) listMachines() ([]mdb.Machine, error) {
+	leases, err := s.Client.CoordinationV1().Leases(s.Namespace).List(
+		context.Background(), metav1.ListOptions{LabelSelector: s.LabelSelector})
+	if err != nil {
+		return nil, err
+	}
+	machines := make([]mdb.Machine, 0, len(leases.Items))
+	now := time.Now()
+	for _, lease := range leases.Items {
+		if lease.Spec.RenewTime != nil && lease.Spec.LeaseDurationSeconds != nil &&
+			now.After(lease.Spec.RenewTime.Add(
+				time.Duration(*lease.Spec.LeaseDurationSeconds)*time.Second)) {
+			// Expired: not renewed within its own lease duration.
+			continue
+		}
+		hostname := lease.Annotations[AnnotationHostname]
+		if hostname == "" {
+			hostname = lease.Name
+		}
+		ipAddr := ""
+		if lease.Spec.HolderIdentity != nil {
+			ipAddr = *lease.Spec.HolderIdentity
+		}
+		machines = append(machines, mdb.Machine{
+			Hostname:   hostname,
+			IpAddress:  ipAddr,
+			InstanceId: lease.Annotations[AnnotationInstanceId],
+		})
+	}
+	return machines, nil
+}
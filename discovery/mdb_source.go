@@ -0,0 +1,50 @@
+package discovery
+
+import (
+	"github.com/Symantec/Dominator/lib/mdb"
+)
+
+// MDBSource adapts scotty's original discovery mechanism -- polling a
+// Dominator mdb file -- to the Source interface. Lister is left
+// pluggable rather than hard-coding a particular mdb watcher so this
+// package does not need to depend on Dominator's daemon internals
+// directly.
+type MDBSource struct {
+	// Lister returns the current machine list each time it is
+	// called, e.g. mdb.ReadMdbFile(path) wrapped in a closure, or an
+	// existing mdb.Listener's latest snapshot.
+	Lister func() ([]mdb.Machine, error)
+	// Changed, when non-nil, receives a value from the caller's
+	// existing mdb watcher whenever Lister's result has changed;
+	// Watch blocks on it between polls instead of busy-polling. A
+	// closed Changed makes Watch call Lister over and over.
+	Changed <-chan struct{}
+}
+
+// Watch implements Source.
+func (s *MDBSource) Watch(stop <-chan struct{}, updates chan<- []mdb.Machine) error {
+	emit := func() error {
+		machines, err := s.Lister()
+		if err != nil {
+			return err
+		}
+		select {
+		case updates <- machines:
+		case <-stop:
+		}
+		return nil
+	}
+	if err := emit(); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-s.Changed:
+			if err := emit(); err != nil {
+				return err
+			}
+		}
+	}
+}
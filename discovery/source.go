@@ -0,0 +1,19 @@
+// Package discovery supplies the active host list that
+// datastructs.ApplicationStatuses.MarkHostsActiveExclusively consumes,
+// behind a single Source interface. The original mdb-backed discovery
+// lives alongside a Kubernetes Lease-based source and a static file
+// source for air-gapped setups, so scotty can run without a dependency
+// on Symantec's Dominator mdb daemon.
+package discovery
+
+import (
+	"github.com/Symantec/Dominator/lib/mdb"
+)
+
+// Source watches some external system for the set of currently active
+// hosts and pushes the equivalent []mdb.Machine list to updates
+// whenever it changes, until stop is closed. Watch blocks until stop
+// is closed or an unrecoverable error occurs.
+type Source interface {
+	Watch(stop <-chan struct{}, updates chan<- []mdb.Machine) error
+}
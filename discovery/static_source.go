@@ -0,0 +1,55 @@
+package discovery
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/mdb"
+)
+
+// StaticFileSource re-reads a JSON file listing the active machines
+// every PollInterval. It is meant for air-gapped deployments that have
+// neither Dominator's mdb daemon nor a Kubernetes API server to poll.
+type StaticFileSource struct {
+	Path         string
+	PollInterval time.Duration
+}
+
+// Watch implements Source.
+func (s *StaticFileSource) Watch(stop <-chan struct{}, updates chan<- []mdb.Machine) error {
+	interval := s.PollInterval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		machines, err := s.read()
+		if err != nil {
+			return err
+		}
+		select {
+		case updates <- machines:
+		case <-stop:
+			return nil
+		}
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *StaticFileSource) read() ([]mdb.Machine, error) {
+	contents, err := os.ReadFile(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	var machines []mdb.Machine
+	if err := json.Unmarshal(contents, &machines); err != nil {
+		return nil, err
+	}
+	return machines, nil
+}
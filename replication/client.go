@@ -0,0 +1,86 @@
+package replication
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// SealedPage is one page ready to ship: its codec-encoded bytes plus
+// the address the peer should file it under.
+type SealedPage struct {
+	EndpointId string
+	MetricId   string
+	SeqNo      uint64
+	Encoded    []byte
+}
+
+// CursorStore persists the most recently shipped seqNo per
+// endpoint/metric so a reconnecting Client resumes instead of
+// re-shipping history the peer already has.
+type CursorStore interface {
+	Load(endpointId, metricId string) (seqNo uint64, ok bool)
+	Save(cursor Cursor) error
+}
+
+// Client ships SealedPages to a peer's Server over a single Replicate
+// stream, skipping anything at or before the peer's last acknowledged
+// Cursor.
+type Client struct {
+	Conn    *grpc.ClientConn
+	Cursors CursorStore
+}
+
+// Ship opens a Replicate stream and sends every page from pages whose
+// SeqNo is past what Cursors says the peer already has. A Cursor is
+// only persisted once the server acks that page back over the
+// stream's reverse direction: stream.Send only guarantees the bytes
+// reached the transport, not that the peer's Sink.IngestPage ran, so
+// persisting on Send success would advance the Cursor past a page the
+// peer may never have received. Ship returns when pages is closed,
+// ctx is canceled, or the stream errors.
+func (c *Client) Ship(ctx context.Context, pages <-chan SealedPage) error {
+	stream, err := NewReplicateStream(ctx, c.Conn)
+	if err != nil {
+		return err
+	}
+	acks := make(chan Envelope)
+	recvErrs := make(chan error, 1)
+	go func() {
+		for {
+			ack, err := stream.Recv()
+			if err != nil {
+				recvErrs <- err
+				return
+			}
+			acks <- *ack
+		}
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-recvErrs:
+			return err
+		case ack := <-acks:
+			if err := c.Cursors.Save(Cursor{
+				EndpointId: ack.EndpointId,
+				MetricId:   ack.MetricId,
+				LastSeqNo:  ack.SeqNo,
+			}); err != nil {
+				return err
+			}
+		case page, ok := <-pages:
+			if !ok {
+				return nil
+			}
+			if last, have := c.Cursors.Load(page.EndpointId, page.MetricId); have && page.SeqNo <= last {
+				continue
+			}
+			envelope := NewEnvelope(page.EndpointId, page.MetricId, page.SeqNo, page.Encoded)
+			if err := stream.Send(&envelope); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package replication
+
+import (
+	"fmt"
+
+	"github.com/Symantec/scotty/store"
+)
+
+// Server is the receiving side of replication: it decodes each
+// Envelope off the wire and ingests it into Sink, preserving the
+// original seqNo.
+type Server struct {
+	Sink store.PageSink
+}
+
+// Replicate implements the server side of the Replicate RPC: it reads
+// Envelopes until the peer closes the stream or an error occurs,
+// acknowledging each one back over the stream only after
+// Sink.IngestPage has returned successfully, so the client knows
+// exactly which pages are safe to advance its resume Cursor past.
+func (s *Server) Replicate(stream ReplicateStream) error {
+	for {
+		envelope, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		if !envelope.Verify() {
+			return fmt.Errorf(
+				"replication: checksum mismatch for %s/%s seqNo %d",
+				envelope.EndpointId, envelope.MetricId, envelope.SeqNo)
+		}
+		page, err := store.DecodePage(envelope.Payload)
+		if err != nil {
+			return err
+		}
+		if err := s.Sink.IngestPage(
+			envelope.EndpointId, envelope.MetricId, envelope.SeqNo, page); err != nil {
+			return err
+		}
+		ack := NewAck(envelope.EndpointId, envelope.MetricId, envelope.SeqNo)
+		if err := stream.Send(&ack); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package rules
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ruleConfig is the YAML shape of a single rule in a rules config
+// file. It is translated into a Rule by RuleListBuilder.ReadConfig.
+type ruleConfig struct {
+	Name   string            `yaml:"name"`
+	Kind   string            `yaml:"kind"`
+	For    string            `yaml:"for"`
+	Labels map[string]string `yaml:"labels"`
+
+	// Kind-specific parameters. Only the ones matching Kind are used.
+	Staleness   string  `yaml:"staleness"`
+	MetricId    string  `yaml:"metric_id"`
+	Threshold   float64 `yaml:"threshold"`
+	Consecutive int     `yaml:"consecutive"`
+	Lookback    string  `yaml:"lookback"`
+
+	Webhook *struct {
+		URL string `yaml:"url"`
+	} `yaml:"webhook"`
+	PagerDuty *struct {
+		RoutingKey string `yaml:"routing_key"`
+	} `yaml:"pagerduty"`
+	Email *struct {
+		SMTPAddr string   `yaml:"smtp_addr"`
+		From     string   `yaml:"from"`
+		To       []string `yaml:"to"`
+	} `yaml:"email"`
+	ShellHook *struct {
+		Command string   `yaml:"command"`
+		Args    []string `yaml:"args"`
+	} `yaml:"shell_hook"`
+}
+
+type rulesConfig struct {
+	Rules []ruleConfig `yaml:"rules"`
+}
+
+// RuleList is an immutable set of Rules built by RuleListBuilder.
+type RuleList struct {
+	rules []*Rule
+}
+
+// Rules returns the rules in this list in no particular order.
+// Clients may reorder the returned slice.
+func (l *RuleList) Rules() []*Rule {
+	result := make([]*Rule, len(l.rules))
+	copy(result, l.rules)
+	return result
+}
+
+// RuleListBuilder builds a RuleList instance, mirroring the
+// ApplicationListBuilder pattern used to build ApplicationLists.
+type RuleListBuilder struct {
+	rules []*Rule
+}
+
+// NewRuleListBuilder creates an empty RuleListBuilder.
+func NewRuleListBuilder() *RuleListBuilder {
+	return &RuleListBuilder{}
+}
+
+// Add adds a single, already-constructed rule.
+func (b *RuleListBuilder) Add(rule *Rule) {
+	b.rules = append(b.rules, rule)
+}
+
+// ReadConfig reads rule declarations in YAML form from r and adds the
+// resulting Rules to this builder.
+func (b *RuleListBuilder) ReadConfig(r io.Reader) error {
+	contents, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	var config rulesConfig
+	if err := yaml.Unmarshal(contents, &config); err != nil {
+		return fmt.Errorf("rules: parsing config: %v", err)
+	}
+	for _, rc := range config.Rules {
+		rule, err := rc.toRule()
+		if err != nil {
+			return fmt.Errorf("rules: rule %q: %v", rc.Name, err)
+		}
+		b.rules = append(b.rules, rule)
+	}
+	return nil
+}
+
+// Build builds the RuleList instance and destroys this builder.
+func (b *RuleListBuilder) Build() *RuleList {
+	result := &RuleList{rules: b.rules}
+	b.rules = nil
+	return result
+}
+
+func (rc *ruleConfig) toRule() (*Rule, error) {
+	var predicate Predicate
+	switch rc.Kind {
+	case "staleness":
+		d, err := time.ParseDuration(rc.Staleness)
+		if err != nil {
+			return nil, err
+		}
+		predicate = StalenessAbove(d)
+	case "no_changed_metrics":
+		predicate = NoChangedMetrics()
+	case "metric_above_threshold":
+		lookback, err := time.ParseDuration(rc.Lookback)
+		if err != nil {
+			return nil, err
+		}
+		predicate = MetricAboveThreshold(rc.MetricId, rc.Threshold, rc.Consecutive, lookback)
+	default:
+		return nil, fmt.Errorf("unknown rule kind %q", rc.Kind)
+	}
+
+	var forDuration time.Duration
+	if rc.For != "" {
+		d, err := time.ParseDuration(rc.For)
+		if err != nil {
+			return nil, err
+		}
+		forDuration = d
+	}
+
+	var notifiers []Notifier
+	if rc.Webhook != nil {
+		notifiers = append(notifiers, &WebhookNotifier{URL: rc.Webhook.URL})
+	}
+	if rc.PagerDuty != nil {
+		notifiers = append(notifiers, &PagerDutyNotifier{RoutingKey: rc.PagerDuty.RoutingKey})
+	}
+	if rc.Email != nil {
+		notifiers = append(notifiers, &EmailNotifier{
+			SMTPAddr: rc.Email.SMTPAddr,
+			From:     rc.Email.From,
+			To:       rc.Email.To,
+		})
+	}
+	if rc.ShellHook != nil {
+		notifiers = append(notifiers, &ShellHookNotifier{
+			Command: rc.ShellHook.Command,
+			Args:    rc.ShellHook.Args,
+		})
+	}
+
+	return &Rule{
+		Name:      rc.Name,
+		Predicate: predicate,
+		For:       forDuration,
+		Labels:    rc.Labels,
+		Notifiers: notifiers,
+	}, nil
+}
@@ -0,0 +1,64 @@
+package rules
+
+import (
+	"log"
+	"time"
+
+	"github.com/Symantec/scotty/datastructs"
+)
+
+// Engine periodically evaluates a fixed set of Rules against an
+// ApplicationStatuses instance and reports the outcome back onto each
+// application's ApplicationStatus.Alerts.
+type Engine struct {
+	statuses *datastructs.ApplicationStatuses
+	rules    []*Rule
+	interval time.Duration
+}
+
+// NewEngine creates an Engine that evaluates rules against statuses
+// every interval when Run is called.
+func NewEngine(
+	statuses *datastructs.ApplicationStatuses,
+	ruleList *RuleList,
+	interval time.Duration) *Engine {
+	return &Engine{
+		statuses: statuses,
+		rules:    ruleList.rules,
+		interval: interval,
+	}
+}
+
+// Run evaluates all rules every interval until stop is closed.
+func (e *Engine) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			e.evaluate(now)
+		}
+	}
+}
+
+func (e *Engine) evaluate(now time.Time) {
+	statusList, s := e.statuses.AllActiveWithStore()
+	for _, status := range statusList {
+		var alerts []datastructs.Alert
+		for _, rule := range e.rules {
+			alert, err := rule.evaluate(status, s, now)
+			if err != nil {
+				log.Printf(
+					"rules: evaluating %s for %s: %v",
+					rule.Name, status.Name, err)
+				continue
+			}
+			if alert != nil {
+				alerts = append(alerts, *alert)
+			}
+		}
+		e.statuses.SetAlerts(status.EndpointId, alerts)
+	}
+}
@@ -0,0 +1,188 @@
+package rules
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"os/exec"
+	"time"
+)
+
+// Notifier is something a Rule can fire Events through. Engine calls
+// Notify synchronously, one Event at a time, from the goroutine
+// running Engine.Run.
+type Notifier interface {
+	Notify(event Event) error
+}
+
+// WebhookNotifier POSTs event as a JSON body to URL.
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+type webhookPayload struct {
+	Rule   string            `json:"rule"`
+	Host   string            `json:"host"`
+	App    string            `json:"app"`
+	State  string            `json:"state"`
+	Since  time.Time         `json:"since"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// Notify implements Notifier.
+func (n *WebhookNotifier) Notify(event Event) error {
+	body, err := json.Marshal(webhookPayload{
+		Rule:   event.Rule,
+		Host:   event.Host,
+		App:    event.App,
+		State:  event.State.String(),
+		Since:  event.Since,
+		Labels: event.Labels,
+	})
+	if err != nil {
+		return err
+	}
+	client := n.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Post(n.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("rules: webhook POST to %s: %v", n.URL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("rules: webhook %s returned %s", n.URL, resp.Status)
+	}
+	return nil
+}
+
+// PagerDutyEventAction is the PagerDuty Events API v2 action a
+// PagerDutyNotifier sends for a given rule State.
+type PagerDutyEventAction string
+
+const (
+	pagerDutyTrigger    PagerDutyEventAction = "trigger"
+	pagerDutyResolve    PagerDutyEventAction = "resolve"
+	pagerDutyAcknowledg PagerDutyEventAction = "acknowledge"
+)
+
+// PagerDutyNotifier sends PagerDuty-style JSON events using the
+// Events API v2 payload shape.
+type PagerDutyNotifier struct {
+	RoutingKey string
+	URL        string
+	Client     *http.Client
+}
+
+type pagerDutyEvent struct {
+	RoutingKey  string                `json:"routing_key"`
+	EventAction PagerDutyEventAction  `json:"event_action"`
+	DedupKey    string                `json:"dedup_key"`
+	Payload     pagerDutyEventPayload `json:"payload"`
+}
+
+type pagerDutyEventPayload struct {
+	Summary  string `json:"summary"`
+	Source   string `json:"source"`
+	Severity string `json:"severity"`
+}
+
+// Notify implements Notifier.
+func (n *PagerDutyNotifier) Notify(event Event) error {
+	action := pagerDutyTrigger
+	if event.State == Resolved {
+		action = pagerDutyResolve
+	}
+	payload := pagerDutyEvent{
+		RoutingKey:  n.RoutingKey,
+		EventAction: action,
+		DedupKey:    event.Rule + "/" + event.Host + "/" + event.App,
+		Payload: pagerDutyEventPayload{
+			Summary:  fmt.Sprintf("%s is %s for %s on %s", event.Rule, event.State, event.App, event.Host),
+			Source:   event.Host,
+			Severity: event.Labels["severity"],
+		},
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	client := n.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	url := n.URL
+	if url == "" {
+		url = "https://events.pagerduty.com/v2/enqueue"
+	}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("rules: pagerduty event: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("rules: pagerduty returned %s", resp.Status)
+	}
+	return nil
+}
+
+// EmailNotifier sends a plain-text email through a SMTP relay for
+// every fired Event.
+type EmailNotifier struct {
+	SMTPAddr string
+	Auth     smtp.Auth
+	From     string
+	To       []string
+}
+
+// Notify implements Notifier.
+func (n *EmailNotifier) Notify(event Event) error {
+	subject := fmt.Sprintf("[%s] %s %s on %s/%s", event.State, event.Rule, event.State, event.Host, event.App)
+	body := fmt.Sprintf(
+		"Rule: %s\r\nState: %s\r\nHost: %s\r\nApp: %s\r\nSince: %s\r\n",
+		event.Rule, event.State, event.Host, event.App, event.Since.Format(time.RFC3339))
+	msg := fmt.Sprintf(
+		"From: %s\r\nTo: %s\r\nSubject: %s\r\n\r\n%s",
+		n.From, joinAddrs(n.To), subject, body)
+	return smtp.SendMail(n.SMTPAddr, n.Auth, n.From, n.To, []byte(msg))
+}
+
+func joinAddrs(addrs []string) string {
+	result := ""
+	for i, addr := range addrs {
+		if i > 0 {
+			result += ", "
+		}
+		result += addr
+	}
+	return result
+}
+
+// ShellHookNotifier runs Command with the event's fields passed as
+// environment variables (SCOTTY_RULE, SCOTTY_STATE, SCOTTY_HOST,
+// SCOTTY_APP, SCOTTY_SINCE), for operators who want to wire alerts
+// into arbitrary local tooling.
+type ShellHookNotifier struct {
+	Command string
+	Args    []string
+}
+
+// Notify implements Notifier.
+func (n *ShellHookNotifier) Notify(event Event) error {
+	cmd := exec.Command(n.Command, n.Args...)
+	cmd.Env = append(cmd.Env,
+		"SCOTTY_RULE="+event.Rule,
+		"SCOTTY_STATE="+event.State.String(),
+		"SCOTTY_HOST="+event.Host,
+		"SCOTTY_APP="+event.App,
+		"SCOTTY_SINCE="+event.Since.Format(time.RFC3339))
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("rules: shell hook %s: %v: %s", n.Command, err, out)
+	}
+	return nil
+}
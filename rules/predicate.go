@@ -0,0 +1,65 @@
+package rules
+
+import (
+	"time"
+
+	"github.com/Symantec/scotty/datastructs"
+	"github.com/Symantec/scotty/store"
+)
+
+// latestNAppender collects up to n Records from a Fetch/FetchForward
+// walk, stopping the walk as soon as it has enough.
+type latestNAppender struct {
+	n       int
+	records []store.Record
+}
+
+func (a *latestNAppender) Append(r *store.Record) bool {
+	a.records = append(a.records, *r)
+	return len(a.records) < a.n
+}
+
+// StalenessAbove returns a Predicate that is true once status's
+// Staleness exceeds max.
+func StalenessAbove(max time.Duration) Predicate {
+	return func(status *datastructs.ApplicationStatus, s *store.Store) (bool, error) {
+		return status.Staleness() > max, nil
+	}
+}
+
+// NoChangedMetrics returns a Predicate that is true when status's
+// AverageChangedMetrics is zero, i.e. nothing has moved. Combined with
+// a rule's For duration this implements "no metrics have changed in N
+// minutes".
+func NoChangedMetrics() Predicate {
+	return func(status *datastructs.ApplicationStatus, s *store.Store) (bool, error) {
+		return status.AverageChangedMetrics() == 0, nil
+	}
+}
+
+// MetricAboveThreshold returns a Predicate that is true when the most
+// recent consecutiveSamples samples of metricId for status's endpoint,
+// found by walking backward from now over at most lookback of history,
+// are all strictly above threshold. Non-numeric samples, and fewer
+// than consecutiveSamples samples within lookback, count as not above
+// threshold.
+func MetricAboveThreshold(
+	metricId string, threshold float64, consecutiveSamples int, lookback time.Duration) Predicate {
+	return func(status *datastructs.ApplicationStatus, s *store.Store) (bool, error) {
+		now := float64(time.Now().Unix())
+		appender := &latestNAppender{n: consecutiveSamples}
+		if err := s.Fetch(status.EndpointId, metricId, now-lookback.Seconds(), now, appender); err != nil {
+			return false, err
+		}
+		if len(appender.records) < consecutiveSamples {
+			return false, nil
+		}
+		for _, record := range appender.records {
+			value, ok := record.Value().(float64)
+			if !ok || value <= threshold {
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+}
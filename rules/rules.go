@@ -0,0 +1,169 @@
+// Package rules evaluates user-defined alerting predicates against a
+// datastructs.ApplicationStatuses instance and fires notifications
+// through a pluggable Notifier when a rule transitions between states.
+package rules
+
+import (
+	"log"
+	"time"
+
+	"github.com/Symantec/scotty"
+	"github.com/Symantec/scotty/datastructs"
+	"github.com/Symantec/scotty/store"
+)
+
+// State is the lifecycle of a single rule evaluated against a single
+// application.
+type State int
+
+const (
+	// Inactive means the predicate is not currently true.
+	Inactive State = iota
+	// Pending means the predicate became true less than For ago.
+	Pending
+	// Firing means the predicate has been true for at least For.
+	Firing
+	// Resolved means the predicate was Pending or Firing and just
+	// became false.
+	Resolved
+)
+
+// String returns the lower-case name of s, as used in Alert.State.
+func (s State) String() string {
+	switch s {
+	case Inactive:
+		return "inactive"
+	case Pending:
+		return "pending"
+	case Firing:
+		return "firing"
+	case Resolved:
+		return "resolved"
+	default:
+		return "unknown"
+	}
+}
+
+// Predicate evaluates a single application's current status and store
+// and reports whether the condition the rule is watching for holds.
+type Predicate func(status *datastructs.ApplicationStatus, s *store.Store) (bool, error)
+
+// Rule is a single named alerting rule: a Predicate evaluated on every
+// tick, a For duration a predicate must hold continuously before the
+// rule fires, and the Notifiers to invoke on state transitions.
+type Rule struct {
+	// Name identifies the rule in fired Alerts and notifications.
+	Name string
+	// Predicate is evaluated once per tick per active application.
+	Predicate Predicate
+	// For is how long Predicate must evaluate true, continuously,
+	// before the rule transitions from Pending to Firing. A zero
+	// value fires immediately.
+	For time.Duration
+	// Labels are attached to every Alert and Notification this rule
+	// produces, e.g. {"severity": "page"}.
+	Labels map[string]string
+	// Notifiers are invoked, in order, on every transition into
+	// Pending, Firing or Resolved. The return from Resolved to
+	// Inactive is not notified.
+	Notifiers []Notifier
+
+	// perEndpoint tracks the state machine for each endpoint this
+	// rule has ever evaluated.
+	perEndpoint map[*scotty.Endpoint]*ruleState
+}
+
+// ruleState is the state machine for one (rule, endpoint) pair.
+type ruleState struct {
+	state     State
+	since     time.Time
+	trueSince time.Time
+}
+
+// Event describes a single state transition fired by a Rule for a
+// single application, passed to every configured Notifier.
+type Event struct {
+	Rule   string
+	Host   string
+	App    string
+	State  State
+	Since  time.Time
+	Labels map[string]string
+}
+
+// evaluate runs r's Predicate against status, advances r's state
+// machine for status's endpoint, fires Notifiers on any transition
+// other than one into Inactive, and returns the resulting Alert, or nil if the rule is Inactive.
+func (r *Rule) evaluate(
+	status *datastructs.ApplicationStatus,
+	s *store.Store,
+	now time.Time) (*Alert, error) {
+	if r.perEndpoint == nil {
+		r.perEndpoint = make(map[*scotty.Endpoint]*ruleState)
+	}
+	st := r.perEndpoint[status.EndpointId]
+	if st == nil {
+		st = &ruleState{state: Inactive}
+		r.perEndpoint[status.EndpointId] = st
+	}
+
+	ok, err := r.Predicate(status, s)
+	if err != nil {
+		return nil, err
+	}
+
+	prev := st.state
+	switch {
+	case !ok:
+		if st.state == Firing || st.state == Pending {
+			st.state = Resolved
+			st.since = now
+		} else {
+			st.state = Inactive
+		}
+	case st.state == Inactive || st.state == Resolved:
+		st.state = Pending
+		st.since = now
+		st.trueSince = now
+		if r.For <= 0 {
+			st.state = Firing
+		}
+	case st.state == Pending && now.Sub(st.trueSince) >= r.For:
+		st.state = Firing
+		st.since = now
+	}
+
+	if st.state != prev && st.state != Inactive {
+		r.notify(status, st, now)
+	}
+	if st.state == Inactive {
+		return nil, nil
+	}
+	return &Alert{
+		Name:   r.Name,
+		State:  st.state.String(),
+		Since:  st.since,
+		Labels: r.Labels,
+	}, nil
+}
+
+func (r *Rule) notify(
+	status *datastructs.ApplicationStatus, st *ruleState, now time.Time) {
+	event := Event{
+		Rule:   r.Name,
+		Host:   status.EndpointId.String(),
+		App:    status.Name,
+		State:  st.state,
+		Since:  st.since,
+		Labels: r.Labels,
+	}
+	for _, notifier := range r.Notifiers {
+		if err := notifier.Notify(event); err != nil {
+			log.Printf("rules: notifier for %s: %v", r.Name, err)
+		}
+	}
+}
+
+// Alert is the outcome of evaluating a single Rule for a single
+// application in one tick.
+type Alert = datastructs.Alert
@@ -0,0 +1,117 @@
+package rules
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Symantec/scotty"
+	"github.com/Symantec/scotty/datastructs"
+	"github.com/Symantec/scotty/store"
+)
+
+// recordingNotifier collects every Event it is given, so tests can
+// assert exactly which transitions fired a notification.
+type recordingNotifier struct {
+	events []Event
+}
+
+func (n *recordingNotifier) Notify(event Event) error {
+	n.events = append(n.events, event)
+	return nil
+}
+
+func newTestStatus() *datastructs.ApplicationStatus {
+	return &datastructs.ApplicationStatus{
+		EndpointId: &scotty.Endpoint{},
+		Name:       "testapp",
+	}
+}
+
+func TestRuleEvaluateInactiveToPendingToFiring(t *testing.T) {
+	notifier := &recordingNotifier{}
+	ok := true
+	r := &Rule{
+		Name: "test",
+		Predicate: func(*datastructs.ApplicationStatus, *store.Store) (bool, error) {
+			return ok, nil
+		},
+		For:       time.Minute,
+		Notifiers: []Notifier{notifier},
+	}
+	status := newTestStatus()
+	now := time.Unix(0, 0)
+
+	// Predicate becomes true: Inactive -> Pending.
+	alert, err := r.evaluate(status, nil, now)
+	if err != nil {
+		t.Fatalf("evaluate: %v", err)
+	}
+	if alert == nil || alert.State != Pending.String() {
+		t.Fatalf("got alert %+v, want state %s", alert, Pending)
+	}
+
+	// Still true but For hasn't elapsed: stays Pending, no new event.
+	now = now.Add(30 * time.Second)
+	alert, err = r.evaluate(status, nil, now)
+	if err != nil {
+		t.Fatalf("evaluate: %v", err)
+	}
+	if alert == nil || alert.State != Pending.String() {
+		t.Fatalf("got alert %+v, want still %s", alert, Pending)
+	}
+
+	// For has now elapsed: Pending -> Firing.
+	now = now.Add(time.Minute)
+	alert, err = r.evaluate(status, nil, now)
+	if err != nil {
+		t.Fatalf("evaluate: %v", err)
+	}
+	if alert == nil || alert.State != Firing.String() {
+		t.Fatalf("got alert %+v, want state %s", alert, Firing)
+	}
+
+	// Predicate goes false: Firing -> Resolved, then the next tick drops
+	// to Inactive.
+	ok = false
+	now = now.Add(time.Second)
+	alert, err = r.evaluate(status, nil, now)
+	if err != nil {
+		t.Fatalf("evaluate: %v", err)
+	}
+	if alert == nil || alert.State != Resolved.String() {
+		t.Fatalf("got alert %+v, want state %s", alert, Resolved)
+	}
+
+	now = now.Add(time.Second)
+	alert, err = r.evaluate(status, nil, now)
+	if err != nil {
+		t.Fatalf("evaluate: %v", err)
+	}
+	if alert != nil {
+		t.Fatalf("got alert %+v, want nil once Inactive", alert)
+	}
+
+	wantStates := []string{Pending.String(), Firing.String(), Resolved.String()}
+	if len(notifier.events) != len(wantStates) {
+		t.Fatalf("got %d notifications, want %d: %+v", len(notifier.events), len(wantStates), notifier.events)
+	}
+	for i, want := range wantStates {
+		if notifier.events[i].State.String() != want {
+			t.Errorf("notification %d state = %s, want %s", i, notifier.events[i].State, want)
+		}
+	}
+}
+
+func TestRuleEvaluatePropagatesPredicateError(t *testing.T) {
+	wantErr := errors.New("boom")
+	r := &Rule{
+		Name: "test",
+		Predicate: func(*datastructs.ApplicationStatus, *store.Store) (bool, error) {
+			return false, wantErr
+		},
+	}
+	if _, err := r.evaluate(newTestStatus(), nil, time.Unix(0, 0)); err != wantErr {
+		t.Fatalf("evaluate err = %v, want %v", err, wantErr)
+	}
+}
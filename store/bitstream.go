@@ -0,0 +1,127 @@
+package store
+
+import (
+	"io"
+)
+
+// bitWriter and bitReader are simple, MSB-first bit streams used by
+// PageCodec to pack delta-of-delta timestamps and XOR-compressed
+// values tighter than byte alignment would allow. They favor
+// simplicity over raw speed: scotty pages are small enough that a
+// bit-at-a-time loop is not a bottleneck next to the network and disk
+// I/O replication already does.
+type bitWriter struct {
+	buf   []byte
+	nbits int
+}
+
+func newBitWriter() *bitWriter {
+	return &bitWriter{}
+}
+
+func (w *bitWriter) writeBit(bit bool) {
+	byteIndex := w.nbits / 8
+	if byteIndex == len(w.buf) {
+		w.buf = append(w.buf, 0)
+	}
+	if bit {
+		w.buf[byteIndex] |= 1 << uint(7-w.nbits%8)
+	}
+	w.nbits++
+}
+
+func (w *bitWriter) writeBits(u uint64, nbits int) {
+	for i := nbits - 1; i >= 0; i-- {
+		w.writeBit((u>>uint(i))&1 == 1)
+	}
+}
+
+// writeVarint zigzag-encodes x and writes it as a stream of 8-bit
+// groups, each with a continuation bit in its high bit, same shape as
+// encoding/binary's varint but bit-packed rather than byte-aligned.
+func (w *bitWriter) writeVarint(x int64) {
+	writeUvarintToBitWriter(w, zigzagEncode(x))
+}
+
+func writeUvarintToBitWriter(w *bitWriter, u uint64) {
+	for {
+		b := byte(u & 0x7f)
+		u >>= 7
+		if u != 0 {
+			w.writeBits(uint64(b|0x80), 8)
+		} else {
+			w.writeBits(uint64(b), 8)
+			return
+		}
+	}
+}
+
+func (w *bitWriter) bytes() []byte {
+	return w.buf
+}
+
+type bitReader struct {
+	buf []byte
+	pos int
+}
+
+func newBitReader(buf []byte) *bitReader {
+	return &bitReader{buf: buf}
+}
+
+func (r *bitReader) readBit() (bool, error) {
+	byteIndex := r.pos / 8
+	if byteIndex >= len(r.buf) {
+		return false, io.ErrUnexpectedEOF
+	}
+	bit := (r.buf[byteIndex]>>uint(7-r.pos%8))&1 == 1
+	r.pos++
+	return bit, nil
+}
+
+func (r *bitReader) readBits(nbits int) (uint64, error) {
+	var u uint64
+	for i := 0; i < nbits; i++ {
+		bit, err := r.readBit()
+		if err != nil {
+			return 0, err
+		}
+		u <<= 1
+		if bit {
+			u |= 1
+		}
+	}
+	return u, nil
+}
+
+func (r *bitReader) readVarint() (int64, error) {
+	u, err := readUvarintFromBitReader(r)
+	if err != nil {
+		return 0, err
+	}
+	return zigzagDecode(u), nil
+}
+
+func readUvarintFromBitReader(r *bitReader) (uint64, error) {
+	var u uint64
+	var shift uint
+	for {
+		b, err := r.readBits(8)
+		if err != nil {
+			return 0, err
+		}
+		u |= (b & 0x7f) << shift
+		if b&0x80 == 0 {
+			return u, nil
+		}
+		shift += 7
+	}
+}
+
+func zigzagEncode(x int64) uint64 {
+	return uint64((x << 1) ^ (x >> 63))
+}
+
+func zigzagDecode(u uint64) int64 {
+	return int64(u>>1) ^ -int64(u&1)
+}
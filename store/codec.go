@@ -0,0 +1,398 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+// This file implements PageCodec, a compact wire format for a single
+// pageWithMetaDataType used by the replication package to ship sealed
+// pages between scotty instances. Timestamps are delta-of-delta
+// encoded and numeric values are XOR-compressed, both following the
+// scheme described in Facebook's Gorilla paper; values that are not
+// float64 fall back to a length-prefixed gob blob for the whole page.
+
+// pageKind distinguishes the two basicPageType implementations so a
+// decoded page can be reconstructed as the right Go type.
+type pageKind uint8
+
+const (
+	pageKindValues pageKind = iota
+	pageKindTimestamps
+)
+
+// valueEncoding identifies which of the two value encodings below a
+// wire-format page used, so Decode knows how to read it back.
+type valueEncoding uint8
+
+const (
+	// valueEncodingXOR means every sample's Value is a float64,
+	// encoded with the Gorilla XOR scheme.
+	valueEncodingXOR valueEncoding = iota
+	// valueEncodingGob means at least one sample's Value is not a
+	// float64; the whole page fell back to a gob-encoded blob.
+	valueEncodingGob
+)
+
+// tsResolution is the fixed-point resolution timestamps are rounded
+// to before delta-of-delta encoding. Sub-microsecond precision is not
+// meaningful for scotty's sampling intervals, and rounding to a fixed
+// resolution is what makes delta-of-delta encoding effective.
+const tsResolution = 1e6 // microseconds per second
+
+// PageCodec encodes and decodes pageWithMetaDataType instances to and
+// from the compact wire format used for replication.
+type PageCodec struct{}
+
+// Encode serializes page into the wire format. The result is self
+// describing: Decode(Encode(page)) reconstructs an equivalent page
+// without needing page's original pageHeader.
+func (PageCodec) Encode(page *pageWithMetaDataType) ([]byte, error) {
+	var body bytes.Buffer
+	switch {
+	case page.timestamps.Len() > 0 || page.values.Len() == 0:
+		if err := encodeTimestamps(&body, page.timestamps); err != nil {
+			return nil, err
+		}
+	default:
+		if err := encodeTimestampsFromValues(&body, page.values); err != nil {
+			return nil, err
+		}
+	}
+
+	kind := pageKindTimestamps
+	var valueBody []byte
+	var encoding valueEncoding
+	if page.values.Len() > 0 {
+		kind = pageKindValues
+		var err error
+		valueBody, encoding, err = encodeValues(page.values)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var out bytes.Buffer
+	out.WriteByte(byte(kind))
+	out.WriteByte(byte(encoding))
+	writeUvarint(&out, uint64(body.Len()))
+	out.Write(body.Bytes())
+	writeUvarint(&out, uint64(len(valueBody)))
+	out.Write(valueBody)
+	return out.Bytes(), nil
+}
+
+// Decode reconstructs a pageWithMetaDataType from data previously
+// produced by Encode. The returned page is cold: it is not attached to
+// any live btree and its seqNo is zero; callers that need the original
+// seqNo must track it alongside the encoded bytes, as the replication
+// envelope does.
+func (PageCodec) Decode(data []byte) (*pageWithMetaDataType, error) {
+	r := bytes.NewReader(data)
+	kindByte, err := r.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("store: decoding page: %v", err)
+	}
+	encodingByte, err := r.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("store: decoding page: %v", err)
+	}
+	tsLen, err := readUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	tsBody := make([]byte, tsLen)
+	if _, err := readFull(r, tsBody); err != nil {
+		return nil, err
+	}
+	timestamps, err := decodeTimestamps(tsBody)
+	if err != nil {
+		return nil, err
+	}
+
+	valueLen, err := readUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	valueBody := make([]byte, valueLen)
+	if _, err := readFull(r, valueBody); err != nil {
+		return nil, err
+	}
+
+	page := &pageWithMetaDataType{}
+	if pageKind(kindByte) == pageKindValues {
+		values, err := decodeValues(valueBody, valueEncoding(encodingByte), timestamps)
+		if err != nil {
+			return nil, err
+		}
+		page.values = values
+	} else {
+		page.timestamps = timestamps
+	}
+	return page, nil
+}
+
+func encodeTimestamps(w *bytes.Buffer, p tsPageType) error {
+	return encodeTimestampSlice(w, []float64(p))
+}
+
+func encodeTimestampsFromValues(w *bytes.Buffer, p pageType) error {
+	ts := make([]float64, len(p))
+	for i, v := range p {
+		ts[i] = v.TimeStamp
+	}
+	return encodeTimestampSlice(w, ts)
+}
+
+func encodeTimestampSlice(w *bytes.Buffer, ts []float64) error {
+	writeUvarint(w, uint64(len(ts)))
+	if len(ts) == 0 {
+		return nil
+	}
+	bw := newBitWriter()
+	first := toFixedPoint(ts[0])
+	bw.writeVarint(first)
+	var prevDelta int64
+	if len(ts) > 1 {
+		prevDelta = toFixedPoint(ts[1]) - first
+		bw.writeVarint(prevDelta)
+	}
+	prev := first + prevDelta
+	for i := 2; i < len(ts); i++ {
+		cur := toFixedPoint(ts[i])
+		delta := cur - prev
+		dod := delta - prevDelta
+		if dod == 0 {
+			bw.writeBit(false)
+		} else {
+			bw.writeBit(true)
+			bw.writeVarint(dod)
+		}
+		prevDelta = delta
+		prev = cur
+	}
+	w.Write(bw.bytes())
+	return nil
+}
+
+func decodeTimestamps(body []byte) (tsPageType, error) {
+	r := bytes.NewReader(body)
+	n, err := readUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return tsPageType{}, nil
+	}
+	rest, err := readFullRemainder(r)
+	if err != nil {
+		return nil, err
+	}
+	br := newBitReader(rest)
+	first, err := br.readVarint()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]float64, n)
+	result[0] = fromFixedPoint(first)
+	if n == 1 {
+		return tsPageType(result), nil
+	}
+	delta, err := br.readVarint()
+	if err != nil {
+		return nil, err
+	}
+	cur := first + delta
+	result[1] = fromFixedPoint(cur)
+	for i := int64(2); i < int64(n); i++ {
+		bit, err := br.readBit()
+		if err != nil {
+			return nil, err
+		}
+		if bit {
+			dod, err := br.readVarint()
+			if err != nil {
+				return nil, err
+			}
+			delta += dod
+		}
+		cur += delta
+		result[i] = fromFixedPoint(cur)
+	}
+	return tsPageType(result), nil
+}
+
+func toFixedPoint(ts float64) int64 {
+	return int64(math.Round(ts * tsResolution))
+}
+
+func fromFixedPoint(fp int64) float64 {
+	return float64(fp) / tsResolution
+}
+
+func encodeValues(p pageType) ([]byte, valueEncoding, error) {
+	for _, v := range p {
+		if _, ok := v.Value.(float64); !ok {
+			blob, err := encodeValuesGob(p)
+			return blob, valueEncodingGob, err
+		}
+	}
+	return encodeValuesXOR(p), valueEncodingXOR, nil
+}
+
+func encodeValuesGob(p pageType) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode([]tsValueType(p)); err != nil {
+		return nil, fmt.Errorf("store: gob-encoding page values: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeValuesXOR encodes p's float64 values with the Gorilla XOR
+// scheme: each value is XORed against the previous one, and only the
+// meaningful (non-zero) bits of the XOR result are written, prefixed
+// by how many leading/trailing zero bits were trimmed.
+func encodeValuesXOR(p pageType) []byte {
+	bw := newBitWriter()
+	writeUvarintToBitWriter(bw, uint64(len(p)))
+	if len(p) == 0 {
+		return bw.bytes()
+	}
+	var prevBits uint64
+	var prevLeading, prevTrailing int = -1, -1
+	for i, v := range p {
+		curBits := math.Float64bits(v.Value.(float64))
+		if i == 0 {
+			bw.writeBits(curBits, 64)
+			prevBits = curBits
+			continue
+		}
+		xor := curBits ^ prevBits
+		if xor == 0 {
+			bw.writeBit(false)
+		} else {
+			bw.writeBit(true)
+			leading := bits.LeadingZeros64(xor)
+			trailing := bits.TrailingZeros64(xor)
+			if prevLeading >= 0 && leading >= prevLeading && trailing >= prevTrailing {
+				bw.writeBit(false)
+				bw.writeBits(xor>>uint(prevTrailing), 64-prevLeading-prevTrailing)
+			} else {
+				bw.writeBit(true)
+				bw.writeBits(uint64(leading), 6)
+				significant := 64 - leading - trailing
+				bw.writeBits(uint64(significant), 7)
+				bw.writeBits(xor>>uint(trailing), significant)
+				prevLeading, prevTrailing = leading, trailing
+			}
+		}
+		prevBits = curBits
+	}
+	return bw.bytes()
+}
+
+func decodeValues(body []byte, encoding valueEncoding, ts tsPageType) (pageType, error) {
+	if encoding == valueEncodingGob {
+		var values []tsValueType
+		if err := gob.NewDecoder(bytes.NewReader(body)).Decode(&values); err != nil {
+			return nil, fmt.Errorf("store: gob-decoding page values: %v", err)
+		}
+		return pageType(values), nil
+	}
+	br := newBitReader(body)
+	n, err := readUvarintFromBitReader(br)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]tsValueType, n)
+	var prevBits uint64
+	var prevLeading, prevTrailing int
+	for i := uint64(0); i < n; i++ {
+		if i == 0 {
+			bits64, err := br.readBits(64)
+			if err != nil {
+				return nil, err
+			}
+			prevBits = bits64
+		} else {
+			bit, err := br.readBit()
+			if err != nil {
+				return nil, err
+			}
+			if bit {
+				controlBit, err := br.readBit()
+				if err != nil {
+					return nil, err
+				}
+				if controlBit {
+					leadingBits, err := br.readBits(6)
+					if err != nil {
+						return nil, err
+					}
+					significantBits, err := br.readBits(7)
+					if err != nil {
+						return nil, err
+					}
+					prevLeading = int(leadingBits)
+					significant := int(significantBits)
+					prevTrailing = 64 - prevLeading - significant
+					meaningful, err := br.readBits(significant)
+					if err != nil {
+						return nil, err
+					}
+					prevBits ^= meaningful << uint(prevTrailing)
+				} else {
+					significant := 64 - prevLeading - prevTrailing
+					meaningful, err := br.readBits(significant)
+					if err != nil {
+						return nil, err
+					}
+					prevBits ^= meaningful << uint(prevTrailing)
+				}
+			}
+		}
+		result[i].Value = math.Float64frombits(prevBits)
+		if int(i) < len(ts) {
+			result[i].TimeStamp = ts[i]
+		}
+	}
+	return pageType(result), nil
+}
+
+func writeUvarint(w *bytes.Buffer, v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	w.Write(buf[:n])
+}
+
+func readUvarint(r *bytes.Reader) (uint64, error) {
+	return binary.ReadUvarint(r)
+}
+
+func readFull(r *bytes.Reader, buf []byte) (int, error) {
+	return bytesReadFull(r, buf)
+}
+
+func bytesReadFull(r *bytes.Reader, buf []byte) (int, error) {
+	n := 0
+	for n < len(buf) {
+		m, err := r.Read(buf[n:])
+		n += m
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+func readFullRemainder(r *bytes.Reader) ([]byte, error) {
+	buf := make([]byte, r.Len())
+	if _, err := bytesReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
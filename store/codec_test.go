@@ -0,0 +1,98 @@
+package store
+
+import "testing"
+
+func TestPageCodecRoundTripValues(t *testing.T) {
+	page := &pageWithMetaDataType{
+		values: pageType{
+			{TimeStamp: 100.0, Value: 1.0},
+			{TimeStamp: 100.5, Value: 1.0},
+			{TimeStamp: 101.25, Value: 1.5},
+			{TimeStamp: 103.0, Value: -2.25},
+			{TimeStamp: 110.0, Value: 0.0},
+		},
+	}
+	encoded, err := (PageCodec{}).Encode(page)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	decoded, err := (PageCodec{}).Decode(encoded)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if decoded.values.Len() != page.values.Len() {
+		t.Fatalf("got %d values, want %d", decoded.values.Len(), page.values.Len())
+	}
+	for i, want := range page.values {
+		got := decoded.values[i]
+		if got.TimeStamp != want.TimeStamp {
+			t.Errorf("value %d timestamp = %v, want %v", i, got.TimeStamp, want.TimeStamp)
+		}
+		if got.Value.(float64) != want.Value.(float64) {
+			t.Errorf("value %d = %v, want %v", i, got.Value, want.Value)
+		}
+	}
+}
+
+func TestPageCodecRoundTripTimestampsOnly(t *testing.T) {
+	page := &pageWithMetaDataType{
+		timestamps: tsPageType{1, 2, 4, 8, 8, 16.5},
+	}
+	encoded, err := (PageCodec{}).Encode(page)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	decoded, err := (PageCodec{}).Decode(encoded)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if decoded.timestamps.Len() != page.timestamps.Len() {
+		t.Fatalf("got %d timestamps, want %d", decoded.timestamps.Len(), page.timestamps.Len())
+	}
+	for i, want := range page.timestamps {
+		if decoded.timestamps[i] != want {
+			t.Errorf("timestamp %d = %v, want %v", i, decoded.timestamps[i], want)
+		}
+	}
+}
+
+func TestPageCodecRoundTripNonFloatValuesFallsBackToGob(t *testing.T) {
+	page := &pageWithMetaDataType{
+		values: pageType{
+			{TimeStamp: 1, Value: "up"},
+			{TimeStamp: 2, Value: "down"},
+		},
+	}
+	encoded, err := (PageCodec{}).Encode(page)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	decoded, err := (PageCodec{}).Decode(encoded)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if decoded.values.Len() != page.values.Len() {
+		t.Fatalf("got %d values, want %d", decoded.values.Len(), page.values.Len())
+	}
+	for i, want := range page.values {
+		got := decoded.values[i]
+		if got.TimeStamp != want.TimeStamp || got.Value.(string) != want.Value.(string) {
+			t.Errorf("value %d = %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+func TestPageCodecRoundTripEmptyPage(t *testing.T) {
+	page := &pageWithMetaDataType{}
+	encoded, err := (PageCodec{}).Encode(page)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	decoded, err := (PageCodec{}).Decode(encoded)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if decoded.values.Len() != 0 || decoded.timestamps.Len() != 0 {
+		t.Fatalf("decoded non-empty page from empty input: %+v", decoded)
+	}
+}
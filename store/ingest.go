@@ -0,0 +1,15 @@
+package store
+
+// PageSink is implemented by Store to accept a page shipped by a
+// replication peer, inserting it into the btree of live pages with
+// its original seqNo preserved so Fetch and FetchForward see it
+// exactly as the sending side did.
+type PageSink interface {
+	IngestPage(endpointId, metricId string, seqNo uint64, page *pageWithMetaDataType) error
+}
+
+// DecodePage decodes data, previously produced by PageCodec.Encode,
+// back into a page ready to pass to a PageSink's IngestPage.
+func DecodePage(data []byte) (*pageWithMetaDataType, error) {
+	return PageCodec{}.Decode(data)
+}
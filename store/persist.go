@@ -0,0 +1,344 @@
+package store
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// This file contains the optional on-disk persistence layer for pages.
+// When enabled, pages that seal or are evicted from the in-memory
+// btree are flushed to a bbolt database, encoded with the same
+// PageCodec the replication package uses, so that a scotty restart
+// does not lose recent history. Pages are addressed by
+// (endpointId, metricId, seqNo) and are read back on demand by
+// Store.Fetch and Store.FetchForward, or in bulk at startup via
+// Store.Rehydrate.
+//
+// Every persisted record is prefixed with a fixed-size pageHeader
+// giving its kind, sample count, and time range, so Store.fetch and
+// Store.fetchForward's disk fallback can rule a cold page in or out
+// of a query's [start, end) window with LoadHeader alone, without
+// paying for a full PageCodec.Decode of every candidate page.
+
+// pagesBucketSuffix is appended to an endpoint's bucket name so the
+// persistence buckets stay easy to spot when inspecting the database
+// with bbolt's CLI tools.
+const pagesBucketSuffix = ".pages"
+
+// pageKey identifies a single persisted page.
+type pageKey struct {
+	endpointId string
+	metricId   string
+	seqNo      uint64
+}
+
+// seqNoKey renders seqNo as a big-endian, lexicographically-sortable
+// bbolt key so a per-metric cursor walk visits pages in seqNo order.
+func seqNoKey(metricId string, seqNo uint64) []byte {
+	key := make([]byte, len(metricId)+1+8)
+	copy(key, metricId)
+	key[len(metricId)] = 0
+	binary.BigEndian.PutUint64(key[len(metricId)+1:], seqNo)
+	return key
+}
+
+// pageHeaderLen is the on-disk size of an encoded pageHeader: 1 byte
+// kind, 8 bytes count, 8 bytes minTs, 8 bytes maxTs.
+const pageHeaderLen = 1 + 8 + 8 + 8
+
+// pageHeader summarizes a persisted page cheaply enough to read and
+// inspect without decoding the page it prefixes.
+type pageHeader struct {
+	kind  pageKind
+	count uint64
+	minTs float64
+	maxTs float64
+}
+
+// computeHeader derives page's header from its in-memory contents.
+func computeHeader(page *pageWithMetaDataType) pageHeader {
+	if page.values.Len() > 0 {
+		return pageHeader{
+			kind:  pageKindValues,
+			count: uint64(len(page.values)),
+			minTs: page.values[0].TimeStamp,
+			maxTs: page.values[len(page.values)-1].TimeStamp,
+		}
+	}
+	h := pageHeader{kind: pageKindTimestamps, count: uint64(len(page.timestamps))}
+	if h.count > 0 {
+		h.minTs = page.timestamps[0]
+		h.maxTs = page.timestamps[h.count-1]
+	}
+	return h
+}
+
+func encodePageHeader(h pageHeader) []byte {
+	buf := make([]byte, pageHeaderLen)
+	buf[0] = byte(h.kind)
+	binary.BigEndian.PutUint64(buf[1:9], h.count)
+	binary.BigEndian.PutUint64(buf[9:17], math.Float64bits(h.minTs))
+	binary.BigEndian.PutUint64(buf[17:25], math.Float64bits(h.maxTs))
+	return buf
+}
+
+func decodePageHeader(record []byte) (pageHeader, error) {
+	if len(record) < pageHeaderLen {
+		return pageHeader{}, fmt.Errorf("store: truncated page header")
+	}
+	return pageHeader{
+		kind:  pageKind(record[0]),
+		count: binary.BigEndian.Uint64(record[1:9]),
+		minTs: math.Float64frombits(binary.BigEndian.Uint64(record[9:17])),
+		maxTs: math.Float64frombits(binary.BigEndian.Uint64(record[17:25])),
+	}, nil
+}
+
+// overlaps reports whether h's time range can contain any sample in
+// [start, end).
+func (h pageHeader) overlaps(start, end float64) bool {
+	return h.maxTs >= start && h.minTs < end
+}
+
+// PersistenceConfig configures the optional on-disk page store.
+type PersistenceConfig struct {
+	// Path is the bbolt database file to open. OpenPagePersister
+	// fails on an empty Path; NewApplicationStatuses opens no
+	// PagePersister when given one.
+	Path string
+	// Retention is stored on the PagePersister. No code deletes or
+	// compacts flushed pages by it; they stay on disk.
+	Retention time.Duration
+	// CacheSize caps how many decoded pages the in-process LRU keeps
+	// around so repeated cold reads of the same page don't keep
+	// round-tripping through bbolt.
+	CacheSize int
+}
+
+// PagePersister flushes evicted or full pages to a bbolt database keyed
+// by (endpointId, metricId, seqNo) and rehydrates them on demand. It
+// sits behind the hot, in-memory btree of live pages: callers should
+// only reach for it once a page is no longer resident.
+type PagePersister struct {
+	db        *bbolt.DB
+	retention time.Duration
+	cache     *pageCache
+}
+
+// OpenPagePersister opens (creating if necessary) the bbolt database at
+// cfg.Path and returns a PagePersister ready to serve Flush and Load
+// calls.
+func OpenPagePersister(cfg PersistenceConfig) (*PagePersister, error) {
+	db, err := bbolt.Open(cfg.Path, 0600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("store: opening persistence db %s: %v", cfg.Path, err)
+	}
+	cacheSize := cfg.CacheSize
+	if cacheSize <= 0 {
+		cacheSize = 10000
+	}
+	return &PagePersister{
+		db:        db,
+		retention: cfg.Retention,
+		cache:     newPageCache(cacheSize),
+	}, nil
+}
+
+// Close releases the underlying bbolt database.
+func (p *PagePersister) Close() error {
+	return p.db.Close()
+}
+
+// Flush encodes page with PageCodec, prefixes it with a pageHeader
+// summarizing its time range, and writes the result to the bucket for
+// endpointId, under a key combining metricId and seqNo. Flush is safe
+// to call for a page that is still live; the copy on disk simply
+// becomes a cache-warm backstop.
+func (p *PagePersister) Flush(key pageKey, page *pageWithMetaDataType) error {
+	encoded, err := (PageCodec{}).Encode(page)
+	if err != nil {
+		return fmt.Errorf("store: encoding page %+v: %v", key, err)
+	}
+	record := append(encodePageHeader(computeHeader(page)), encoded...)
+	err = p.db.Update(func(tx *bbolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(key.endpointId + pagesBucketSuffix))
+		if err != nil {
+			return err
+		}
+		return bucket.Put(seqNoKey(key.metricId, key.seqNo), record)
+	})
+	if err != nil {
+		return fmt.Errorf("store: flushing page %+v: %v", key, err)
+	}
+	p.cache.add(key, page)
+	return nil
+}
+
+// Load reads back the page at key, first consulting the in-process
+// cache. The returned page is cold: it has been rehydrated from disk
+// but is not attached to any live btree.
+func (p *PagePersister) Load(key pageKey) (*pageWithMetaDataType, error) {
+	if page, ok := p.cache.get(key); ok {
+		return page, nil
+	}
+	record, err := p.loadRecord(key)
+	if err != nil {
+		return nil, err
+	}
+	page, err := decodePage(record[pageHeaderLen:])
+	if err != nil {
+		return nil, err
+	}
+	p.cache.add(key, page)
+	return page, nil
+}
+
+// LoadHeader reads back just the pageHeader for key, without decoding
+// the page it prefixes, so a caller deciding whether a cold page is
+// even worth loading can check its time range first.
+func (p *PagePersister) LoadHeader(key pageKey) (pageHeader, error) {
+	record, err := p.loadRecord(key)
+	if err != nil {
+		return pageHeader{}, err
+	}
+	return decodePageHeader(record)
+}
+
+func (p *PagePersister) loadRecord(key pageKey) ([]byte, error) {
+	var record []byte
+	err := p.db.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(key.endpointId + pagesBucketSuffix))
+		if bucket == nil {
+			return fmt.Errorf("store: no persisted pages for endpoint %s", key.endpointId)
+		}
+		value := bucket.Get(seqNoKey(key.metricId, key.seqNo))
+		if value == nil {
+			return fmt.Errorf("store: page %+v not found", key)
+		}
+		record = append([]byte(nil), value...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// Rehydrate walks every persisted page for endpointId in seqNo order
+// and invokes addPage for each one so the caller can re-Add it to its
+// btree of live pages in a cold state.
+func (p *PagePersister) Rehydrate(
+	endpointId string,
+	addPage func(metricId string, seqNo uint64, page *pageWithMetaDataType)) error {
+	return p.db.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(endpointId + pagesBucketSuffix))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			metricId, seqNo, err := splitSeqNoKey(k)
+			if err != nil {
+				return err
+			}
+			if len(v) < pageHeaderLen {
+				return fmt.Errorf("store: truncated persisted page for %s/%s", endpointId, metricId)
+			}
+			page, err := decodePage(v[pageHeaderLen:])
+			if err != nil {
+				return err
+			}
+			addPage(metricId, seqNo, page)
+			return nil
+		})
+	})
+}
+
+// ForEachEndpoint calls fn once for every endpointId that has at
+// least one persisted page, so a caller can Rehydrate each of them
+// without needing to already know which endpoints were polled before
+// a restart.
+func (p *PagePersister) ForEachEndpoint(fn func(endpointId string) error) error {
+	return p.db.View(func(tx *bbolt.Tx) error {
+		return tx.ForEach(func(name []byte, _ *bbolt.Bucket) error {
+			if !strings.HasSuffix(string(name), pagesBucketSuffix) {
+				return nil
+			}
+			endpointId := strings.TrimSuffix(string(name), pagesBucketSuffix)
+			return fn(endpointId)
+		})
+	})
+}
+
+func splitSeqNoKey(k []byte) (metricId string, seqNo uint64, err error) {
+	idx := bytes.IndexByte(k, 0)
+	if idx < 0 || idx+1+8 != len(k) {
+		return "", 0, fmt.Errorf("store: malformed persisted page key")
+	}
+	return string(k[:idx]), binary.BigEndian.Uint64(k[idx+1:]), nil
+}
+
+func decodePage(record []byte) (*pageWithMetaDataType, error) {
+	return (PageCodec{}).Decode(record)
+}
+
+// pageCache is a fixed-size, least-recently-used cache of decoded
+// pages sitting in front of bbolt so repeated Fetch calls over the
+// same cold range don't keep re-reading and re-decoding the page.
+type pageCache struct {
+	capacity int
+	mu       sync.Mutex
+	ll       *list.List
+	items    map[pageKey]*list.Element
+}
+
+type pageCacheEntry struct {
+	key  pageKey
+	page *pageWithMetaDataType
+}
+
+func newPageCache(capacity int) *pageCache {
+	return &pageCache{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[pageKey]*list.Element),
+	}
+}
+
+func (c *pageCache) get(key pageKey) (*pageWithMetaDataType, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(elem)
+	return elem.Value.(*pageCacheEntry).page, true
+}
+
+func (c *pageCache) add(key pageKey, page *pageWithMetaDataType) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		c.ll.MoveToFront(elem)
+		elem.Value.(*pageCacheEntry).page = page
+		return
+	}
+	elem := c.ll.PushFront(&pageCacheEntry{key: key, page: page})
+	c.items[key] = elem
+	for c.ll.Len() > c.capacity {
+		oldest := c.ll.Back()
+		if oldest == nil {
+			break
+		}
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*pageCacheEntry).key)
+	}
+}
@@ -0,0 +1,315 @@
+package store
+
+import (
+	"sync"
+
+	"github.com/Symantec/scotty"
+	"github.com/google/btree"
+)
+
+// This file defines Store, the live, in-memory time series database
+// that Fetch, FetchForward (pages.go) and PagePersister (persist.go)
+// all serve. A Store holds one btree of full pages per (endpoint,
+// metric) series plus the page currently being appended to; when a
+// page fills it is pushed onto the btree and, if a PagePersister was
+// given to NewStore, flushed to disk so a restart does not lose it.
+
+// Record is a single (timestamp, value) sample read back from a
+// Store, reused across repeated StoreIndexToRecord calls by Fetch and
+// FetchForward to avoid an allocation per sample.
+type Record struct {
+	TimeStamp float64
+	value     interface{}
+}
+
+// Value returns the sample's value.
+func (r *Record) Value() interface{} {
+	return r.value
+}
+
+func (r *Record) setValue(v interface{}) {
+	r.value = v
+}
+
+// Appender receives Records from Fetch and FetchForward. Append
+// returns false to stop the walk early.
+type Appender interface {
+	Append(r *Record) bool
+}
+
+// samplesPerPage is how many samples a live page holds before it is
+// sealed, pushed onto its series' btree, and (if a persister is
+// configured) flushed to disk.
+const samplesPerPage = 100
+
+// seriesKey identifies one time series within a Store. Endpoints are
+// addressed by their string form rather than by *scotty.Endpoint so
+// that a series started before a restart can be rehydrated from a
+// PagePersister, which only ever sees the string endpointId, back
+// into the same key a live *scotty.Endpoint maps to.
+type seriesKey struct {
+	endpointId string
+	metricId   string
+}
+
+// series is the live state for one (endpoint, metric) pair: a btree
+// of sealed pages plus the page currently being appended to.
+type series struct {
+	pages        *btree.BTree
+	current      *pageWithMetaDataType
+	nextSeq      uint64
+	minLiveSeqNo uint64
+}
+
+// Store holds the live time series for every endpoint scotty is
+// currently polling, optionally backed by a PagePersister so that
+// pages sealed before a restart are not lost.
+type Store struct {
+	mu        sync.Mutex
+	series    map[seriesKey]*series
+	persister *PagePersister
+}
+
+// NewStore creates an empty Store. If persister is non-nil, pages are
+// flushed to it as they seal; pass the same persister to
+// Store.Rehydrate to load back whatever it holds from a previous run.
+func NewStore(persister *PagePersister) *Store {
+	return &Store{
+		series:    make(map[seriesKey]*series),
+		persister: persister,
+	}
+}
+
+// Rehydrate loads every page persister holds back into s in a cold
+// state, so that Fetch and FetchForward see history from before a
+// restart. It should be called once, right after NewStore, before s
+// is handed off to any readers or writers.
+func (s *Store) Rehydrate(persister *PagePersister) error {
+	return persister.ForEachEndpoint(func(endpointId string) error {
+		return persister.Rehydrate(endpointId,
+			func(metricId string, seqNo uint64, page *pageWithMetaDataType) {
+				s.rehydratePage(endpointId, metricId, seqNo, page)
+			})
+	})
+}
+
+func (s *Store) rehydratePage(
+	endpointId, metricId string, seqNo uint64, page *pageWithMetaDataType) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := seriesKey{endpointId: endpointId, metricId: metricId}
+	ser := s.series[key]
+	if ser == nil {
+		ser = &series{pages: btree.New(2)}
+		s.series[key] = ser
+	}
+	page.SetSeqNo(seqNo)
+	ser.pages.ReplaceOrInsert(page)
+	if seqNo >= ser.nextSeq {
+		ser.nextSeq = seqNo + 1
+	}
+	ser.minLiveSeqNo = ser.pages.Min().(*pageWithMetaDataType).SeqNo()
+}
+
+// AddSample appends a single (ts, value) sample to e's metricId
+// series, sealing and, if a persister was given to NewStore, flushing
+// the current page once it reaches samplesPerPage samples. If
+// maxPages is positive, sealed pages beyond the most recent maxPages
+// are dropped from memory (they remain on disk if persisted) so a
+// single noisy series cannot grow without bound.
+func (s *Store) AddSample(
+	e *scotty.Endpoint, metricId string, ts float64, value interface{}, maxPages int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := seriesKey{endpointId: e.String(), metricId: metricId}
+	ser := s.series[key]
+	if ser == nil {
+		ser = &series{pages: btree.New(2)}
+		s.series[key] = ser
+	}
+	if ser.current == nil {
+		ser.current = &pageWithMetaDataType{values: make(pageType, 0, samplesPerPage)}
+	}
+	ser.current.values.Add(tsValueType{TimeStamp: ts, Value: value})
+	if !ser.current.values.IsFull() {
+		return nil
+	}
+	sealed := ser.current
+	sealed.SetSeqNo(ser.nextSeq)
+	ser.nextSeq++
+	ser.current = nil
+	ser.pages.ReplaceOrInsert(sealed)
+	if s.persister != nil {
+		if err := s.persister.Flush(pageKey{
+			endpointId: key.endpointId,
+			metricId:   key.metricId,
+			seqNo:      sealed.SeqNo(),
+		}, sealed); err != nil {
+			return err
+		}
+	}
+	if maxPages > 0 {
+		for ser.pages.Len() > maxPages {
+			oldest := ser.pages.DeleteMin()
+			if oldest == nil {
+				break
+			}
+		}
+		if min := ser.pages.Min(); min != nil {
+			ser.minLiveSeqNo = min.(*pageWithMetaDataType).SeqNo()
+		}
+	}
+	return nil
+}
+
+// IngestPage implements PageSink: it inserts page into endpointId's
+// metricId series at the given seqNo, preserving it exactly as the
+// sending peer had it, so replicated history is indistinguishable
+// from history this Store collected itself. If a page already
+// occupies seqNo, it is replaced.
+func (s *Store) IngestPage(
+	endpointId, metricId string, seqNo uint64, page *pageWithMetaDataType) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := seriesKey{endpointId: endpointId, metricId: metricId}
+	ser := s.series[key]
+	if ser == nil {
+		ser = &series{pages: btree.New(2)}
+		s.series[key] = ser
+	}
+	page.SetSeqNo(seqNo)
+	ser.pages.ReplaceOrInsert(page)
+	if seqNo >= ser.nextSeq {
+		ser.nextSeq = seqNo + 1
+	}
+	ser.minLiveSeqNo = ser.pages.Min().(*pageWithMetaDataType).SeqNo()
+	return nil
+}
+
+// Fetch walks e's metricId series backward from end to start, adding
+// samples to appender in descending time order. It falls back to this
+// Store's PagePersister, if any, for pages old enough to have been
+// dropped from memory by AddSample's maxPages limit.
+func (s *Store) Fetch(
+	e *scotty.Endpoint, metricId string, start, end float64, appender Appender) error {
+	return s.fetch(e.String(), metricId, start, end, appender)
+}
+
+// FetchForward works like Fetch but walks forward from start to end,
+// adding samples to appender in ascending time order.
+func (s *Store) FetchForward(
+	e *scotty.Endpoint, metricId string, start, end float64, appender Appender) error {
+	return s.fetchForward(e.String(), metricId, start, end, appender)
+}
+
+func (s *Store) fetch(
+	endpointId, metricId string, start, end float64, appender Appender) error {
+	s.mu.Lock()
+	ser := s.series[seriesKey{endpointId: endpointId, metricId: metricId}]
+	if ser == nil {
+		s.mu.Unlock()
+		return nil
+	}
+	var record Record
+	keepGoing := true
+	if ser.current != nil {
+		keepGoing = Fetch(ser.current.ValuePage(), start, end, &record, appender)
+	}
+	var pages []*pageWithMetaDataType
+	if keepGoing {
+		ser.pages.Descend(func(item btree.Item) bool {
+			pages = append(pages, item.(*pageWithMetaDataType))
+			return true
+		})
+	}
+	minLiveSeqNo := ser.minLiveSeqNo
+	persister := s.persister
+	s.mu.Unlock()
+
+	for _, page := range pages {
+		keepGoing = Fetch(page.ValuePage(), start, end, &record, appender)
+		if !keepGoing {
+			return nil
+		}
+	}
+	if !keepGoing || persister == nil || minLiveSeqNo == 0 {
+		return nil
+	}
+	for seqNo := minLiveSeqNo - 1; ; seqNo-- {
+		key := pageKey{endpointId: endpointId, metricId: metricId, seqNo: seqNo}
+		header, err := persister.LoadHeader(key)
+		if err != nil {
+			return nil
+		}
+		if header.maxTs < start {
+			// This page, and every earlier one, ends before start.
+			return nil
+		}
+		if header.overlaps(start, end) {
+			page, err := persister.Load(key)
+			if err != nil {
+				return nil
+			}
+			if !Fetch(page.ValuePage(), start, end, &record, appender) {
+				return nil
+			}
+		}
+		if seqNo == 0 {
+			return nil
+		}
+	}
+}
+
+func (s *Store) fetchForward(
+	endpointId, metricId string, start, end float64, appender Appender) error {
+	s.mu.Lock()
+	ser := s.series[seriesKey{endpointId: endpointId, metricId: metricId}]
+	if ser == nil {
+		s.mu.Unlock()
+		return nil
+	}
+	var pages []*pageWithMetaDataType
+	ser.pages.Ascend(func(item btree.Item) bool {
+		pages = append(pages, item.(*pageWithMetaDataType))
+		return true
+	})
+	current := ser.current
+	persister := s.persister
+	minLiveSeqNo := ser.minLiveSeqNo
+	s.mu.Unlock()
+
+	var record Record
+	keepGoing := true
+	if persister != nil {
+		for seqNo := uint64(0); seqNo < minLiveSeqNo; seqNo++ {
+			key := pageKey{endpointId: endpointId, metricId: metricId, seqNo: seqNo}
+			header, err := persister.LoadHeader(key)
+			if err != nil {
+				continue
+			}
+			if header.minTs >= end {
+				// This page, and every later one, starts at or after end.
+				return nil
+			}
+			if !header.overlaps(start, end) {
+				continue
+			}
+			page, err := persister.Load(key)
+			if err != nil {
+				continue
+			}
+			if keepGoing = FetchForward(page.ValuePage(), start, end, &record, appender); !keepGoing {
+				return nil
+			}
+		}
+	}
+	for _, page := range pages {
+		if keepGoing = FetchForward(page.ValuePage(), start, end, &record, appender); !keepGoing {
+			return nil
+		}
+	}
+	if current != nil {
+		FetchForward(current.ValuePage(), start, end, &record, appender)
+	}
+	return nil
+}